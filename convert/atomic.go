@@ -0,0 +1,145 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// WriteFileAtomic calls write with a temp file in the same folder as path and renames it over path once it is
+// synced and passes check, so readers of path only ever see the old or the complete new file. The temp file is
+// deleted if anything fails. check is skipped if nil. Paths that are not regular files, like /dev/null, are
+// written directly.
+func WriteFileAtomic(path string, write func(out *os.File) error, check func(path string) error) error {
+	var err error
+	perm := os.FileMode(0666) // same as os.Create before the umask
+	info, statErr := os.Stat(path)
+	if statErr == nil {
+		if !info.Mode().IsRegular() {
+			return writeFileDirect(path, write)
+		}
+		perm = info.Mode().Perm()
+	}
+
+	var tmpFile *os.File
+	if tmpFile, err = createTempFile(path, perm); err != nil {
+		return &IOError{Op: fmt.Sprintf("Could not create output file %v.", path), Err: err}
+	}
+	tmpPath := tmpFile.Name()
+	done := false
+	defer func() {
+		if !done {
+			tmpFile.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err = write(tmpFile); err != nil {
+		return err
+	}
+	if err = tmpFile.Sync(); err != nil {
+		return &IOError{Op: "Writing to output file failed.", Err: err}
+	}
+	if err = tmpFile.Close(); err != nil {
+		return &IOError{Op: "Writing to output file failed.", Err: err}
+	}
+	if check != nil {
+		if err = check(tmpPath); err != nil {
+			return err
+		}
+	}
+	if statErr == nil { // keep the permissions of the old file, the umask may have masked them
+		if err = os.Chmod(tmpPath, perm); err != nil {
+			return &IOError{Op: fmt.Sprintf("Could not create output file %v.", path), Err: err}
+		}
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return &IOError{Op: fmt.Sprintf("Could not replace output file %v.", path), Err: err}
+	}
+	done = true
+
+	// make the rename durable, not supported on all platforms so the error is ignored
+	if dir, dirErr := os.Open(filepath.Dir(path)); dirErr == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// createTempFile creates a new hidden file next to path, the file mode is masked by the umask like os.Create
+func createTempFile(path string, perm os.FileMode) (*os.File, error) {
+	dir, base := filepath.Split(path)
+	for i := 0; ; i++ {
+		suffix := strconv.FormatInt(time.Now().UnixNano()+int64(i), 36)
+		f, err := os.OpenFile(filepath.Join(dir, "."+base+"."+suffix+".tmp"), os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if err == nil || !errors.Is(err, os.ErrExist) || i >= 100 {
+			return f, err
+		}
+	}
+}
+
+// writeFileDirect calls write with path opened for writing
+func writeFileDirect(path string, write func(out *os.File) error) error {
+	outFile, err := os.Create(path)
+	if err != nil {
+		return &IOError{Op: fmt.Sprintf("Could not create output file %v.", path), Err: err}
+	}
+	defer outFile.Close()
+
+	if err = write(outFile); err != nil {
+		return err
+	}
+	if err = outFile.Close(); err != nil {
+		return &IOError{Op: "Writing to output file failed.", Err: err}
+	}
+	return nil
+}
+
+// CheckBINFile checks that the BIN file is complete. The header is valid, the file size matches the header and
+// the first and last rows of both IP families can be read.
+func CheckBINFile(path string) error {
+	rdr, err := OpenBIN(path)
+	if err != nil {
+		return &IOError{Op: "Output file is not a valid BIN file.", Err: err}
+	}
+	defer rdr.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return &IOError{Op: "Output file is not a valid BIN file.", Err: err}
+	}
+	if rdr.Header.FileSize != 0 && int64(rdr.Header.FileSize) != info.Size() {
+		return &IOError{Op: fmt.Sprintf("Output file is %d bytes instead of %d bytes.", info.Size(), rdr.Header.FileSize)}
+	}
+
+	for _, ipv6 := range []bool{false, true} {
+		count := rdr.RowCount(ipv6)
+		if count == 0 {
+			continue
+		}
+		for _, row := range []uint32{0, count - 1} {
+			if _, _, err = rdr.ReadRow(ipv6, row); err != nil {
+				return &IOError{Op: "Output file is not a valid BIN file.", Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+// CheckMMDBFile checks that the MMDB file is complete with the verifier of the MMDB reader
+func CheckMMDBFile(path string) error {
+	rdr, err := maxminddb.Open(path)
+	if err != nil {
+		return &IOError{Op: "Output file is not a valid MMDB file.", Err: err}
+	}
+	defer rdr.Close()
+
+	if err = rdr.Verify(); err != nil {
+		return &IOError{Op: "Output file is not a valid MMDB file.", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package convert
+
+import (
+	"bufio"
+	"io"
+)
+
+// ConvertBIN2CSV writes the BIN rows as the IP2Location CSV, IPv6 CSV if the BIN has IPv6 rows.
+func ConvertBIN2CSV(rdr *BINReader, out io.Writer) error {
+	var err error
+
+	outFileBuffered := bufio.NewWriterSize(out, 65536)
+
+	err = WalkBINAsCSV(rdr, func(parts []string) error {
+		WriteCSVRecord(outFileBuffered, parts)
+		return nil
+	})
+	if err != nil {
+		return &IOError{Op: "Unable to read input file.", Err: err}
+	}
+
+	if err = outFileBuffered.Flush(); err != nil {
+		return &IOError{Op: "Writing to output file failed.", Err: err}
+	}
+	return SyncOutput(out)
+}
+
+// WalkBINAsCSV calls emit with the same records as the IP2Location IPv6 CSV, or IPv4 CSV for an IPv4-only BIN
+func WalkBINAsCSV(rdr *BINReader, emit func(parts []string) error) error {
+	var err error
+	wtr := &csvRangeWriter{emit: emit, empty: rdr.EmptyRow()}
+
+	if rdr.RowCount(true) == 0 { // IPv4-only BIN so output plain IPv4 numbers
+		err = walkBINRanges(rdr, false, func(start uint128, end uint128, fields []string) error {
+			wtr.Add(start, end, fields, false)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return wtr.Close(maxIPv4Number)
+	}
+
+	// IPv4 rows are stored as plain IPv4 so need to put them back into the IPv4-mapped IPv6 range,
+	// replacing whatever the IPv6 rows have for that range. For IP2Location the IPv4-mapped range is kept apart
+	// from the IPv6 ranges around it like in the LITE CSV, csv2bin merges the lines at the boundaries again.
+	split := func() {
+		if rdr.Header.ProductCode != 2 {
+			wtr.Split()
+		}
+	}
+	ipv4Done := false
+	addIPv4 := func() error {
+		ipv4Done = true
+		split()
+		err := walkBINRanges(rdr, false, func(start uint128, end uint128, fields []string) error {
+			wtr.Add(start.add(ipv4MappedStartNumber), end.add(ipv4MappedStartNumber), fields, false)
+			return nil
+		})
+		split()
+		return err
+	}
+
+	err = walkBINRanges(rdr, true, func(start uint128, end uint128, fields []string) error {
+		if start.cmp(ipv4MappedStartNumber) < 0 {
+			if end.cmp(ipv4MappedStartNumber) < 0 {
+				wtr.Add(start, end, fields, false)
+			} else {
+				wtr.Add(start, ipv4MappedStartNumber.subOne(), fields, true)
+			}
+		}
+		if end.cmp(ipv4MappedStartNumber) >= 0 && !ipv4Done {
+			if err := addIPv4(); err != nil {
+				return err
+			}
+		}
+		if end.cmp(ipv4MappedEndNumber) > 0 {
+			if start.cmp(ipv4MappedEndNumber) > 0 {
+				wtr.Add(start, end, fields, false)
+			} else {
+				wtr.Add(ipv4MappedEndNumber.addOne(), end, fields, true)
+			}
+		}
+		return nil
+	})
+	if err == nil && !ipv4Done {
+		err = addIPv4()
+	}
+	if err != nil {
+		return err
+	}
+	return wtr.Close(maxIPv6Number)
+}
+
+// walkBINRanges calls fn with the start, end and fields of each range in the IPv4 or IPv6 section
+func walkBINRanges(rdr *BINReader, ipv6 bool, fn func(start uint128, end uint128, fields []string) error) error {
+	count := rdr.RowCount(ipv6)
+	if count < 2 {
+		return nil
+	}
+
+	start, fields, err := rdr.ReadRow(ipv6, 0)
+	if err != nil {
+		return err
+	}
+
+	for row := uint32(1); row < count; row++ {
+		var nextStart uint128
+		var nextFields []string
+		if row == count-1 {
+			// last row only marks the end of the section
+			if nextStart, err = rdr.ReadIPFrom(ipv6, row); err != nil {
+				return err
+			}
+			if err = fn(start, nextStart, fields); err != nil {
+				return err
+			}
+			break
+		}
+
+		if nextStart, nextFields, err = rdr.ReadRow(ipv6, row); err != nil {
+			return err
+		}
+		if err = fn(start, nextStart.subOne(), fields); err != nil {
+			return err
+		}
+		start = nextStart
+		fields = nextFields
+	}
+	return nil
+}
+
+// csvRangeWriter fills gaps between ranges with placeholder rows. Ranges that did not come straight from a
+// BIN row (gaps and ranges cut at the IPv4-mapped boundaries) are merged into neighbours with the same fields
+// so that the output has the same shape as the original CSV.
+type csvRangeWriter struct {
+	emit      func(parts []string) error
+	err       error // first error from emit, the ranges after it are dropped
+	next      ipCursor
+	empty     []string
+	pending   bool // start, end and fields have a range not written out yet
+	start     uint128
+	end       uint128
+	fields    []string
+	synthetic bool
+}
+
+func (c *csvRangeWriter) Add(start uint128, end uint128, fields []string, synthetic bool) {
+	if !c.next.before(start) && start != c.next.next {
+		c.push(c.next.next, start.subOne(), c.empty, true)
+	}
+	c.push(start, end, fields, synthetic)
+}
+
+func (c *csvRangeWriter) push(start uint128, end uint128, fields []string, synthetic bool) {
+	if c.pending && (c.synthetic || synthetic) && EqualFields(c.fields, fields) {
+		c.end = end
+		c.synthetic = c.synthetic && synthetic
+	} else {
+		c.flush()
+		c.pending = true
+		c.start = start
+		c.end = end
+		c.fields = fields
+		c.synthetic = synthetic
+	}
+	c.next.advance(end)
+}
+
+func (c *csvRangeWriter) flush() {
+	if c.pending && c.err == nil {
+		c.err = c.emit(concatSlice([]string{c.start.String(), c.end.String()}, c.fields))
+	}
+}
+
+// Split writes out the pending range so that it is not merged with the next range
+func (c *csvRangeWriter) Split() {
+	c.flush()
+	c.pending = false
+}
+
+// Close fills the range up to the max IP number and writes out the last row
+func (c *csvRangeWriter) Close(max uint128) error {
+	if !c.next.before(max) {
+		c.push(c.next.next, max, c.empty, true)
+	}
+	c.flush()
+	c.pending = false
+	return c.err
+}
@@ -0,0 +1,50 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+// TestBIN2CSVRoundTrip checks that the CSV from bin2csv has the LITE lines at the IPv4-mapped boundaries and gives
+// the same BIN again
+func TestBIN2CSVRoundTrip(t *testing.T) {
+	lite := readTestdata(t, "db5.csv")
+	opts := BINOptions{Package: 5, Date: testDate}
+
+	var bin1 bytes.Buffer
+	if err := WriteBIN(bytes.NewReader(lite), &bin1, opts); err != nil {
+		t.Fatal(err)
+	}
+	rdr, err := NewBINReader(bytes.NewReader(bin1.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = ConvertBIN2CSV(rdr, &out); err != nil {
+		t.Fatal(err)
+	}
+	var bin2 bytes.Buffer
+	if err = WriteBIN(bytes.NewReader(out.Bytes()), &bin2, opts); err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, "BIN", bin2.Bytes(), bin1.Bytes())
+
+	// the floats lose precision in the BIN so only the ranges are compared
+	want, err := csv.NewReader(bytes.NewReader(lite)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i][0] != want[i][0] || got[i][1] != want[i][1] {
+			t.Errorf("line %d is %v-%v, want %v-%v", i+1, got[i][0], got[i][1], want[i][0], want[i][1])
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package convert
+
+import (
+	"github.com/maxmind/mmdbwriter"
+	"io"
+)
+
+// ConvertBIN2MMDB converts the BIN into MMDB without writing the CSV. The BIN rows go through the same
+// records as bin2csv and then through the csv2mmdb functions so the MMDB is the same as converting the CSV.
+// The MMDB type is empty to convert all the fields of the DB package.
+func ConvertBIN2MMDB(rdr *BINReader, out io.Writer, opts MMDBOptions) error {
+	var err error
+	mmdbType := opts.Type
+
+	dbType := rdr.Header.DBType
+	isProxy := rdr.Header.ProductCode == 2
+
+	var dbDesc string
+	if isProxy {
+		if mmdbType != "" && mmdbType != "proxy" {
+			return newClassError(ErrOption, "IP2Proxy BIN can only be converted to the proxy MMDB type.")
+		}
+		mmdbType = "proxy"
+		dbDesc = "IP2Proxy database"
+	} else if mmdbType == "" {
+		dbDesc = DBPackageMMDBType(dbType)
+	} else if mmdbType == "country" {
+		dbDesc = "GeoLite2Country database"
+	} else if mmdbType == "city" {
+		if regionPosition[dbType] == 0 || cityPosition[dbType] == 0 || latitudePosition[dbType] == 0 || zipCodePosition[dbType] == 0 {
+			return newClassError(ErrOption, "DB%d BIN does not have the DB9 fields.", dbType)
+		}
+		dbDesc = "GeoLite2City database"
+	} else {
+		return newClassError(ErrOption, "Invalid MMDB type.")
+	}
+
+	// columns to pick from the CSV records to get the DB9 columns
+	var cityIndexes []int
+	if mmdbType == "city" {
+		names := concatSlice([]string{"ip_from", "ip_to"}, rdr.ColumnNames())
+		for _, name := range []string{"ip_from", "ip_to", "country_code", "country_name", "region", "city", "latitude", "longitude", "zip_code"} {
+			for i, v := range names {
+				if v == name {
+					cityIndexes = append(cityIndexes, i)
+				}
+			}
+		}
+	}
+
+	date := opts.Date
+	if date.IsZero() {
+		if date, err = rdr.Date(); err != nil {
+			return err
+		}
+	}
+
+	var tree *mmdbwriter.Tree
+	if tree, err = NewMMDBTree(dbDesc, date); err != nil {
+		return err
+	}
+
+	delim := ','
+	appendRecord := func(parts []string) error {
+		if mmdbType == "proxy" {
+			return AppendProxyCSVRecord(delim, parts, tree, dbType)
+		} else if mmdbType == "country" {
+			return AppendDB1CSVRecord(delim, parts, tree)
+		} else if mmdbType == "city" {
+			cityParts := make([]string, len(cityIndexes))
+			for i, index := range cityIndexes {
+				cityParts[i] = parts[index]
+			}
+			return AppendDB9CSVRecord(delim, cityParts, tree)
+		}
+		return AppendDBCSVRecord(delim, parts, tree, dbType)
+	}
+	err = WalkBINAsCSV(rdr, func(parts []string) error {
+		// the MMDB keeps the IPv4 networks apart, so the ranges merged over the ends of the IPv4-mapped range are
+		// split there like the IP2Location CSV has them
+		start, _ := parseUint128(parts[0])
+		end, _ := parseUint128(parts[1])
+		for _, last := range []uint128{ipv4MappedStartNumber.subOne(), ipv4MappedEndNumber} {
+			if start.cmp(last) <= 0 && end.cmp(last) > 0 {
+				if err := appendRecord(concatSlice([]string{start.String(), last.String()}, parts[2:])); err != nil {
+					return err
+				}
+				start = last.addOne()
+				parts = concatSlice([]string{start.String(), parts[1]}, parts[2:])
+			}
+		}
+		return appendRecord(parts)
+	})
+	if err != nil {
+		return recordError(err)
+	}
+
+	if _, err := tree.WriteTo(out); err != nil {
+		return &IOError{Op: "Writing out to tree failed.", Err: err}
+	}
+	return SyncOutput(out)
+}
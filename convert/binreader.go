@@ -0,0 +1,340 @@
+package convert
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	columnString  uint8 = iota // pointer to a length-prefixed string
+	columnCountry              // pointer to the country short name, the long name is 3 bytes after it
+	columnFloat                // float32 stored directly in the row
+)
+
+type binColumn struct {
+	name     string
+	position []uint8
+	kind     uint8
+}
+
+// same order as the fields are written by WriteBIN
+var ip2locationColumns = []binColumn{
+	{"country", countryPosition[:], columnCountry},
+	{"region", regionPosition[:], columnString},
+	{"city", cityPosition[:], columnString},
+	{"latitude", latitudePosition[:], columnFloat},
+	{"longitude", longitudePosition[:], columnFloat},
+	{"zip_code", zipCodePosition[:], columnString},
+	{"time_zone", timeZonePosition[:], columnString},
+	{"isp", ispPosition[:], columnString},
+	{"domain", domainPosition[:], columnString},
+	{"net_speed", netSpeedPosition[:], columnString},
+	{"idd_code", iddCodePosition[:], columnString},
+	{"area_code", areaCodePosition[:], columnString},
+	{"weather_station_code", weatherStationCodePosition[:], columnString},
+	{"weather_station_name", weatherStationNamePosition[:], columnString},
+	{"mcc", mccPosition[:], columnString},
+	{"mnc", mncPosition[:], columnString},
+	{"mobile_brand", mobileBrandPosition[:], columnString},
+	{"elevation", elevationPosition[:], columnString},
+	{"usage_type", usageTypePosition[:], columnString},
+	{"address_type", addressTypePosition[:], columnString},
+	{"category", categoryPosition[:], columnString},
+	{"district", districtPosition[:], columnString},
+	{"asn", asnPosition[:], columnString},
+	{"as", asPosition[:], columnString},
+}
+
+type BINHeader struct {
+	DBType        uint8
+	DBColl        uint8
+	DBYear        uint8
+	DBMonth       uint8
+	DBDay         uint8
+	IPv4Count     uint32
+	IPv4Base      uint32
+	IPv6Count     uint32
+	IPv6Base      uint32
+	IPv4IndexBase uint32
+	IPv6IndexBase uint32
+	ProductCode   uint8
+	ProductType   uint8
+	FileSize      uint32
+}
+
+type BINReader struct {
+	file    io.ReaderAt
+	closer  io.Closer // set when the file was opened by OpenBIN
+	Header  BINHeader
+	columns []binColumn // columns enabled for the DB package, in row order
+	strs    map[uint32]string
+}
+
+// OpenBIN opens the BIN file and reads the header, the file is closed by Close
+func OpenBIN(input string) (*BINReader, error) {
+	var err error
+	var inFile *os.File
+	if inFile, err = os.Open(input); err != nil {
+		return nil, err
+	}
+
+	var r *BINReader
+	if r, err = NewBINReader(inFile); err != nil {
+		inFile.Close()
+		return nil, err
+	}
+	r.closer = inFile
+	return r, nil
+}
+
+// NewBINReader reads the BIN header from in and returns the reader for the rows
+func NewBINReader(in io.ReaderAt) (*BINReader, error) {
+	r := &BINReader{file: in, strs: map[uint32]string{}}
+
+	buf := make([]byte, 64)
+	if _, err := in.ReadAt(buf, 0); err != nil {
+		return nil, &IOError{Op: "Unable to read BIN header.", Err: err}
+	}
+
+	h := &r.Header
+	h.DBType = buf[0]
+	h.DBColl = buf[1]
+	h.DBYear = buf[2]
+	h.DBMonth = buf[3]
+	h.DBDay = buf[4]
+	h.IPv4Count = binary.LittleEndian.Uint32(buf[5:])
+	h.IPv4Base = binary.LittleEndian.Uint32(buf[9:])
+	h.IPv6Count = binary.LittleEndian.Uint32(buf[13:])
+	h.IPv6Base = binary.LittleEndian.Uint32(buf[17:])
+	h.IPv4IndexBase = binary.LittleEndian.Uint32(buf[21:])
+	h.IPv6IndexBase = binary.LittleEndian.Uint32(buf[25:])
+	h.ProductCode = buf[29]
+	h.ProductType = buf[30]
+	h.FileSize = binary.LittleEndian.Uint32(buf[31:])
+
+	// older BIN files leave the product code as 0
+	if h.ProductCode == 2 {
+		if h.DBType < 1 || h.DBType > 12 || h.DBColl != pxColumnSize[h.DBType] {
+			return nil, newClassError(ErrInvalidData, "Unsupported BIN file.")
+		}
+	} else if h.ProductCode > 1 || h.DBType < 1 || h.DBType > 26 || h.DBColl != columnSize[h.DBType] {
+		return nil, newClassError(ErrInvalidData, "Unsupported BIN file.")
+	}
+
+	r.columns, _ = BINColumns(h.ProductCode, h.DBType)
+	return r, nil
+}
+
+// Date returns the date of the database in the header
+func (r *BINReader) Date() (time.Time, error) {
+	return headerDate(r.Header.DBYear, r.Header.DBMonth, r.Header.DBDay)
+}
+
+func (r *BINReader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// ColumnNames returns the CSV column names after the IP from and IP to columns
+func (r *BINReader) ColumnNames() []string {
+	names := []string{}
+	for _, col := range r.columns {
+		if col.kind == columnCountry {
+			names = append(names, "country_code", "country_name")
+		} else {
+			names = append(names, col.name)
+		}
+	}
+	return names
+}
+
+// EmptyRow returns the placeholder values used for ranges without data
+func (r *BINReader) EmptyRow() []string {
+	return EmptyColumnFields(r.columns)
+}
+
+func (r *BINReader) RowCount(ipv6 bool) uint32 {
+	if ipv6 {
+		return r.Header.IPv6Count
+	}
+	return r.Header.IPv4Count
+}
+
+func (r *BINReader) readRowBytes(ipv6 bool, row uint32) ([]byte, error) {
+	rowSize := uint32(r.Header.DBColl) * 4
+	base := r.Header.IPv4Base - 1
+	if ipv6 {
+		rowSize += 12 // IPv6 address is 16 bytes instead of 4
+		base = r.Header.IPv6Base - 1
+	}
+
+	buf := make([]byte, rowSize)
+	if _, err := r.file.ReadAt(buf, int64(base)+int64(row)*int64(rowSize)); err != nil {
+		return nil, &IOError{Op: "Unable to read BIN row.", Err: err}
+	}
+	return buf, nil
+}
+
+// readIPFrom returns the starting IP number of the row bytes, stored as little endian
+func readIPFrom(ipv6 bool, buf []byte) uint128 {
+	if ipv6 {
+		return uint128{hi: binary.LittleEndian.Uint64(buf[8:]), lo: binary.LittleEndian.Uint64(buf)}
+	}
+	return uint128{lo: uint64(binary.LittleEndian.Uint32(buf))}
+}
+
+// ReadIPFrom returns only the starting IP number of the row
+func (r *BINReader) ReadIPFrom(ipv6 bool, row uint32) (uint128, error) {
+	buf, err := r.readRowBytes(ipv6, row)
+	if err != nil {
+		return uint128{}, err
+	}
+	return readIPFrom(ipv6, buf), nil
+}
+
+// ReadRow returns the starting IP number of the row and the decoded fields in CSV column order
+func (r *BINReader) ReadRow(ipv6 bool, row uint32) (uint128, []string, error) {
+	buf, err := r.readRowBytes(ipv6, row)
+	if err != nil {
+		return uint128{}, nil, err
+	}
+
+	ipLen := uint32(4)
+	if ipv6 {
+		ipLen = 16
+	}
+
+	fields := []string{}
+	for _, col := range r.columns {
+		offset := ipLen + (uint32(col.position[r.Header.DBType])-2)*4
+		data := binary.LittleEndian.Uint32(buf[offset:])
+
+		if col.kind == columnFloat {
+			fields = append(fields, FormatCoordinate(math.Float32frombits(data)))
+			continue
+		}
+
+		str, err := r.ReadString(data)
+		if err != nil {
+			return uint128{}, nil, err
+		}
+		fields = append(fields, str)
+
+		if col.kind == columnCountry {
+			if data != 0 { // zero pointer already gave "-" for the short name
+				if str, err = r.ReadString(data + 3); err != nil {
+					return uint128{}, nil, err
+				}
+			}
+			fields = append(fields, str)
+		}
+	}
+	return readIPFrom(ipv6, buf), fields, nil
+}
+
+// FindRow returns the row with the IP address in the IPv4 or IPv6 section, using the index on the first 2 octets
+// to narrow down the rows for the binary search
+func (r *BINReader) FindRow(ip net.IP) (bool, uint32, bool, error) {
+	ipv6 := false
+	var ipNum uint128
+	if v4 := ip.To4(); v4 != nil { // includes IPv4-mapped IPv6
+		ipNum = uint128FromBytes(v4)
+	} else if v6 := ip.To16(); v6 != nil {
+		ipv6 = true
+		ipNum = uint128FromBytes(v6)
+	} else {
+		return false, 0, false, errors.New("Invalid IP address.")
+	}
+
+	count := r.RowCount(ipv6)
+	if count < 2 {
+		return ipv6, 0, false, nil // IPv4-only BIN has no IPv6 rows
+	}
+
+	max := maxIPv4Number
+	indexBase := r.Header.IPv4IndexBase
+	if ipv6 {
+		max = maxIPv6Number
+		indexBase = r.Header.IPv6IndexBase
+	}
+	if ipNum == max {
+		ipNum = ipNum.subOne() // the ending record starts at the last IP so use the range before it
+	}
+	first2Octet := uint32(ipNum.lo >> 16)
+	if ipv6 {
+		first2Octet = ipNum.first2Octet()
+	}
+
+	low := uint32(0)
+	high := count - 2 // last row only marks the end of the section
+	if indexBase > 0 {
+		buf := make([]byte, 8)
+		offset := int64(indexBase-1) + int64(first2Octet)*8
+		if _, err := r.file.ReadAt(buf, offset); err != nil {
+			return ipv6, 0, false, &IOError{Op: "Unable to read BIN index.", Err: err}
+		}
+		low = binary.LittleEndian.Uint32(buf)
+		high = binary.LittleEndian.Uint32(buf[4:])
+	}
+
+	for low <= high && high <= count-2 {
+		mid := low + (high-low)/2
+		ipFrom, err := r.ReadIPFrom(ipv6, mid)
+		if err != nil {
+			return ipv6, 0, false, err
+		}
+		ipTo, err := r.ReadIPFrom(ipv6, mid+1)
+		if err != nil {
+			return ipv6, 0, false, err
+		}
+
+		if ipNum.cmp(ipFrom) >= 0 && ipNum.cmp(ipTo) < 0 {
+			return ipv6, mid, true, nil
+		} else if ipNum.cmp(ipFrom) < 0 {
+			if mid == 0 {
+				break
+			}
+			high = mid - 1
+		} else {
+			low = mid + 1
+		}
+	}
+	return ipv6, 0, false, nil
+}
+
+// ReadString dereferences a string pointer from the row
+func (r *BINReader) ReadString(ptr uint32) (string, error) {
+	if str, ok := r.strs[ptr]; ok {
+		return str, nil
+	}
+
+	// WriteBIN stores a zero pointer when a placeholder value is not in the dictionary
+	if ptr == 0 {
+		return "-", nil
+	}
+
+	buf := make([]byte, 256) // 1 byte length + up to 255 bytes of data
+	n, err := r.file.ReadAt(buf, int64(ptr))
+	if n == 0 || (err != nil && err != io.EOF) || int(buf[0]) >= n {
+		return "", &IOError{Op: "Unable to read BIN string.", Err: err}
+	}
+
+	str := string(buf[1 : 1+int(buf[0])])
+	r.strs[ptr] = str
+	return str, nil
+}
+
+// FormatCoordinate returns the float32 latitude or longitude of the BIN with the 6 decimals of the CSV. The float32
+// is taken as its shortest decimal first, so 34.05223 comes back as 34.052230 and not as 34.052231.
+func FormatCoordinate(v float32) string {
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'f', -1, 32), 64)
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
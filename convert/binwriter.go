@@ -0,0 +1,261 @@
+package convert
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"time"
+)
+
+// binRangeFunc receives a range with the fields in the CSV column order after the IP from and IP to columns
+type binRangeFunc func(ipv6 bool, start uint128, end uint128, fields []string) error
+
+// BINColumns returns the columns enabled for the package, in row order, and the column count in the row
+func BINColumns(productCode uint8, dbType uint8) ([]binColumn, uint8) {
+	allColumns := ip2locationColumns
+	dbColl := columnSize[dbType]
+	if productCode == 2 {
+		allColumns = ip2proxyColumns
+		dbColl = pxColumnSize[dbType]
+	}
+
+	columns := []binColumn{}
+	for _, col := range allColumns {
+		if col.position[dbType] > 0 {
+			columns = append(columns, col)
+		}
+	}
+	return columns, dbColl
+}
+
+// EmptyColumnFields returns the placeholder values used for ranges without data
+func EmptyColumnFields(columns []binColumn) []string {
+	fields := []string{}
+	for _, col := range columns {
+		if col.kind == columnCountry {
+			fields = append(fields, "-", "-")
+		} else if col.kind == columnFloat {
+			fields = append(fields, "0.000000")
+		} else {
+			fields = append(fields, "-")
+		}
+	}
+	return fields
+}
+
+// WriteRangesBIN writes the same BIN layout as WriteBIN from the ranges given by walk. The walk is done twice,
+// first to collect the strings and build the index and then to write the rows, so it must give the same ranges
+// both times with the gaps already filled in and all the IPv4 ranges before the IPv6 ranges. The walk should not
+// parse the input again, WriteProxyBIN walks the records it keeps in its record store. date is the date of the
+// database in the header, it is required.
+func WriteRangesBIN(out io.Writer, productCode uint8, dbType uint8, ipv6 bool, date time.Time, walk func(fn binRangeFunc) error) error {
+	dbYear, dbMonth, dbDay, err := binDate(date)
+	if err != nil {
+		return err
+	}
+	var dbProductType uint8 = 3 // 1 for commercial, 2 for LITE, 3 for generated by this script
+
+	columns, dbColl := BINColumns(productCode, dbType)
+	fieldIndexes := []int{} // where to find the column in the fields since the country has 2 fields
+	fieldCount := 0
+	for _, col := range columns {
+		fieldIndexes = append(fieldIndexes, fieldCount)
+		if col.kind == columnCountry {
+			fieldCount += 2
+		} else {
+			fieldCount++
+		}
+	}
+
+	var ipv4IndexBase uint32 = 64
+	var ipv6IndexBase uint32 = ipv4IndexBase + (256 * 256 * 8)
+	var ipv4Base uint32 = ipv6IndexBase + (256 * 256 * 8)
+	var ipv4RowSize uint32 = uint32(dbColl) * 4
+	var ipv6RowSize uint32 = ipv4RowSize + 12 // IPv6 address is 16 bytes instead of 4
+	var ipv4Count uint32 = 0
+	var ipv6Count uint32 = 0
+	var ipv4IndexRowMin [65536]uint32
+	var ipv4IndexRowMax [65536]uint32
+	var ipv6IndexRowMin [65536]uint32
+	var ipv6IndexRowMax [65536]uint32
+
+	// need "-" in every column for the gaps and the ending records
+	dicts := make([]map[string]uint32, len(columns))
+	for i := range dicts {
+		dicts[i] = map[string]uint32{"-": 0}
+	}
+	countryLong := map[string]string{"-": "-"}
+
+	err = walk(func(isIPv6 bool, start uint128, end uint128, fields []string) error {
+		if len(fields) != fieldCount {
+			return ErrInvalidData
+		}
+		for i, col := range columns {
+			v := fields[fieldIndexes[i]]
+			if col.kind == columnFloat {
+				if _, err := strconv.ParseFloat(v, 64); err != nil {
+					return ErrInvalidData
+				}
+				continue
+			}
+			dicts[i][v] = 0
+			if col.kind == columnCountry {
+				if _, ok := countryLong[v]; !ok {
+					countryLong[v] = fields[fieldIndexes[i]+1]
+				}
+			}
+		}
+
+		if isIPv6 {
+			no2From := start.first2Octet()
+			no2To := end.first2Octet()
+			for no2 := no2From; no2 <= no2To; no2++ {
+				if ipv6IndexRowMax[no2] == 0 { // stores row + 1 so that 0 means not set
+					ipv6IndexRowMin[no2] = ipv6Count
+				}
+				ipv6IndexRowMax[no2] = ipv6Count + 1
+			}
+			ipv6Count++
+		} else {
+			no2From := uint32(start.lo >> 16)
+			no2To := uint32(end.lo >> 16)
+			for no2 := no2From; no2 <= no2To; no2++ {
+				if ipv4IndexRowMax[no2] == 0 {
+					ipv4IndexRowMin[no2] = ipv4Count
+				}
+				ipv4IndexRowMax[no2] = ipv4Count + 1
+			}
+			ipv4Count++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ipv4Count++ // ending record
+	if ipv6 {
+		ipv6Count++
+	}
+
+	ipv6Base := ipv4Base + ipv4Count*ipv4RowSize
+	addr := ipv6Base + ipv6Count*ipv6RowSize
+
+	sorted := make([][]string, len(columns))
+	for i, col := range columns {
+		if col.kind == columnFloat {
+			continue
+		}
+		sorted[i] = GetSortedKeys(dicts[i])
+		for _, v := range sorted[i] {
+			dicts[i][v] = addr
+			if col.kind == columnCountry {
+				addr = addr + 1 + 2 + 1 + uint32(len(countryLong[v]))
+			} else {
+				addr = addr + 1 + uint32(len(v))
+			}
+		}
+	}
+	dbFileSize := addr
+
+	outFileBuffered := bufio.NewWriterSize(out, 65536) // keeps the first write error until the flush
+
+	var header = []any{
+		dbType,
+		dbColl,
+		dbYear,
+		dbMonth,
+		dbDay,
+		ipv4Count,
+		ipv4Base + 1,
+		ipv6Count,
+		ipv6Base + 1,
+		ipv4IndexBase + 1,
+		ipv6IndexBase + 1,
+		productCode,
+		dbProductType,
+		dbFileSize,
+	}
+
+	for _, v := range header {
+		// due to different data types, we loop and let the Write perform the conversion to bytes
+		WriteMe(outFileBuffered, v)
+	}
+
+	bytes := make([]byte, 63-35+1) // bunch of zero bytes
+	WriteMe(outFileBuffered, bytes)
+
+	for no2 := 0; no2 < 65536; no2++ {
+		WriteMe(outFileBuffered, ipv4IndexRowMin[no2])
+		WriteMe(outFileBuffered, ipv4IndexRowMax[no2]-1) // the ranges have no gaps so all are set
+	}
+	for no2 := 0; no2 < 65536; no2++ {
+		if ipv6 {
+			WriteMe(outFileBuffered, ipv6IndexRowMin[no2])
+			WriteMe(outFileBuffered, ipv6IndexRowMax[no2]-1)
+		} else {
+			WriteMe(outFileBuffered, make([]byte, 8)) // IPv4-only BIN has empty IPv6 index
+		}
+	}
+
+	writeRow := func(ipBytes []byte, fields []string) { // IP number already in little endian
+		WriteMe(outFileBuffered, ipBytes)
+		for i, col := range columns {
+			v := fields[fieldIndexes[i]]
+			if col.kind == columnFloat {
+				f, _ := strconv.ParseFloat(v, 64) // already checked in the first walk
+				WriteMe(outFileBuffered, float32(f))
+			} else {
+				WriteMe(outFileBuffered, dicts[i][v])
+			}
+		}
+	}
+
+	empty := EmptyColumnFields(columns)
+	ipv4Ending := false
+	err = walk(func(isIPv6 bool, start uint128, end uint128, fields []string) error {
+		if isIPv6 && !ipv4Ending {
+			ipv4Ending = true
+			writeRow(ipv4LittleEndian(uint32(maxIPv4Number.lo)), empty)
+		}
+		if isIPv6 {
+			writeRow(start.littleEndian(), fields)
+		} else {
+			writeRow(ipv4LittleEndian(uint32(start.lo)), fields)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// dealing with ending record
+	if !ipv4Ending {
+		writeRow(ipv4LittleEndian(uint32(maxIPv4Number.lo)), empty)
+	}
+	if ipv6 {
+		writeRow(maxIPv6Number.littleEndian(), empty)
+	}
+
+	for i, col := range columns {
+		for _, v := range sorted[i] {
+			WriteMe(outFileBuffered, uint8(len(v)))
+			WriteMe(outFileBuffered, v)
+			if col.kind == columnCountry {
+				if v == "-" {
+					WriteMe(outFileBuffered, " ") // to make it 2 bytes
+				}
+				WriteMe(outFileBuffered, uint8(len(countryLong[v])))
+				WriteMe(outFileBuffered, countryLong[v])
+			}
+		}
+	}
+
+	if err = outFileBuffered.Flush(); err != nil {
+		return &IOError{Op: "Writing to output file failed.", Err: err}
+	}
+	if err = SyncOutput(out); err != nil {
+		return &IOError{Op: "Error flushing to disk.", Err: err}
+	}
+	return nil
+}
@@ -1,4 +1,4 @@
-package main
+package convert
 
 import (
 	"bufio"
@@ -6,11 +6,9 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"math/big"
-	"net"
-	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var countryPosition = [27]uint8{0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2}
@@ -44,24 +42,72 @@ type countryType struct {
 	long string
 }
 
-func WriteBIN(input string, output string, dbPackage string) {
-	var err error
+// BINOptions are the options for WriteBIN and WriteProxyBIN
+type BINOptions struct {
+	Package  uint8  // 1 to 26 for the IP2Location DB package or 1 to 12 for the IP2Proxy PX package
+	IPFamily string // "4" or "6" to check the IP version of the CSV, detected from the CSV if empty
+
+	// OnError is called for the bad CSV records instead of stopping at the first one, the ranges of the records
+	// left out are filled with "-" records. Use SkipRecords or QuarantineRecords.
+	OnError RecordErrorFunc
+
+	// Jobs is the number of goroutines parsing the CSV, 1 or less parses it on the calling goroutine. The output
+	// is the same for any number.
+	Jobs int
+
+	Date time.Time // date of the database in the BIN header, required
+}
+
+// rewriteLITERecord changes the LITE record the way the BIN stores it, false means the BIN leaves the record out.
+// The 2 lines at the start and the 2 lines at the IPv4/IPv6 boundary become 1 line each and UK is stored as GB.
+func rewriteLITERecord(parts []string) bool {
+	if parts[0] == "0" && parts[1] == "281470681743359" {
+		return false // just skip the uncompressed line at the start of LITE
+	}
+	if parts[0] == "281470681743360" && parts[1] == "1470698520575" {
+		parts[0] = "0" // compress the 2 lines at the start of LITE into 1 line
+	}
+
+	if parts[0] == "281474439839744" && parts[1] == "281474976710655" {
+		return false // just skip the uncompressed line in the LITE for the IPv4/IPv6 boundary
+	}
+	if parts[0] == "281474976710656" && parts[1] == "42540528726795050063891204319802818559" {
+		parts[0] = "281474439839744" // compress the 2 lines in the LITE for the IPv4/IPv6 boundary into 1 line
+	}
+
+	if parts[2] == "UK" {
+		parts[2] = "GB"
+	}
+	return true
+}
+
+// WriteBIN converts the IP2Location CSV into IP2Location BIN. The CSV is only read once, the records are kept in a
+// temp file for the passes over them, so neither the input nor the output needs to be seekable.
+func WriteBIN(in io.Reader, out io.Writer, opts BINOptions) (err error) {
+	// line number and record of the CSV record being converted, set in all passes to report the record errors
+	var csvLine int
+	var csvRecord []string
+	defer func() {
+		if err != nil && csvRecord != nil {
+			err = lineError(err, csvLine, csvRecord)
+		}
+	}()
+
 	var ispCase uint8 = 0 // need to perform some data manipulation if CSV is IPv6 and contains ISP field
 
-	var dbYear uint8 = 21
-	var dbMonth uint8 = 1
-	var dbDay uint8 = 20
 	var dbProductCode uint8 = 1 // 1 for IP2Location, 2 for IP2Proxy
 	var dbProductType uint8 = 3 // 1 for commercial, 2 for LITE, 3 for generated by this script
-	var dbFileSize uint32 = 0   // dummy, calculate later after file written
-
-	var dbType64 uint64
+	var dbFileSize uint32 = 0   // calculated after the string addresses
 
-	if dbType64, err = strconv.ParseUint(dbPackage, 10, 8); err != nil {
-		fmt.Println("Invalid DB package.")
-		return
+	dbType := opts.Package
+	if dbType < 1 || dbType > 26 {
+		return newClassError(ErrOption, "Invalid DB package.")
 	}
-	dbType := uint8(dbType64)
+	dbYear, dbMonth, dbDay, err := binDate(opts.Date)
+	if err != nil {
+		return err
+	}
+	ipFamily := opts.IPFamily
 	var ipv4IndexBase uint32 = 64
 	var ipv6IndexBase uint32 = ipv4IndexBase + (256 * 256 * 8)
 	ipv4IndexRowMin := make(map[uint32]uint32, 65535)
@@ -150,52 +196,83 @@ func WriteBIN(input string, output string, dbPackage string) {
 	lastIPv4To := ""
 	lastIPv6To := ""
 
-	var inFile *os.File
-	if inFile, err = os.Open(input); err != nil {
-		fmt.Printf("Invalid input file %v.\n", input)
-		return
+	// the CSV is parsed once into the record store, the passes below read the records from there
+	records, err := newRecordStore()
+	if err != nil {
+		return err
 	}
-	defer inFile.Close()
-
-	delim := ','
-	var rdr *csv.Reader
-	inFileBuffered := bufio.NewReaderSize(inFile, 65536)
+	defer records.Close()
 
-	csvRdr := csv.NewReader(inFileBuffered)
-	csvRdr.Comma = delim
-	csvRdr.LazyQuotes = false
-
-	rdr = csvRdr
+	csvRdr, stopCSV := newCSVReader(in, false, opts.Jobs, opts.OnError != nil) // column count is checked below for the DB package
+	defer stopCSV()
+	if opts.OnError != nil {
+		csvRdr = newLenientCSVReader(csvRdr, dbType, ipFamily, &recordErrorHandler{onError: opts.OnError})
+	}
 
-	lines := 0
+	detectedFamily := "4"
+	var next ipCursor
 	for {
-		parts, err := rdr.Read()
+		csvRecord = nil
+		parts, err := csvRdr.Read()
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			fmt.Println("Unable to read input file.")
-			return
+			if opts.OnError == nil { // the lenient reader already returns the error to stop with
+				err = csvReadError(err)
+			}
+			return err
 		}
+		csvLine, _ = csvRdr.FieldPos(0)
+		csvRecord = parts
 
-		if parts[0] == "0" && parts[1] == "281470681743359" {
-			continue // just skip the uncompressed line at the start of LITE
+		if len(parts) != int(dbColl)+2 {
+			return &ColumnCountError{Package: fmt.Sprintf("DB%d", dbType), Expected: []int{int(dbColl) + 2}, Found: len(parts)}
 		}
-		if parts[0] == "281470681743360" && parts[1] == "1470698520575" {
-			parts[0] = "0" // compress the 2 lines at the start of LITE into 1 line
+
+		// the BIN only keeps the IP from of each range so the ranges must follow each other without gaps,
+		// checked before the LITE lines are changed in the passes below
+		if next, err = nextIPRange(parts, recordIPRange(csvRdr, parts), next); err != nil {
+			return err
+		}
+		if next.pastIPv4() { // IPv6 CSV can have IPv4 numbers at the start
+			detectedFamily = "6"
+		}
+
+		if err = records.Write(csvLine, parts); err != nil {
+			return err
 		}
+	}
+	csvRecord = nil
+
+	if ipFamily == "" {
+		ipFamily = detectedFamily
+	} else if ipFamily != detectedFamily {
+		return ipFamilyError(ipFamily)
+	}
+
+	var rdr csvRecordReader
+	if rdr, err = records.Reader(); err != nil { // first pass for the string addresses and the index
+		return err
+	}
 
-		if parts[0] == "281474439839744" && parts[1] == "281474976710655" {
-			continue // just skip the uncompressed line in the LITE for the IPv4/IPv6 boundary
+	lines := 0
+	for {
+		csvRecord = nil
+		parts, err := rdr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
 		}
-		if parts[0] == "281474976710656" && parts[1] == "42540528726795050063891204319802818559" {
-			parts[0] = "281474439839744" // compress the 2 lines in the LITE for the IPv4/IPv6 boundary into 1 line
+		csvLine, _ = rdr.FieldPos(0)
+		csvRecord = append([]string(nil), parts...) // parts is changed below for the LITE lines
+
+		if !rewriteLITERecord(parts) {
+			continue
 		}
 
 		lines++
 
-		if parts[2] == "UK" {
-			parts[2] = "GB"
-		}
 		if countryEnabled {
 			if _, ok := country[parts[2]]; !ok { // if map key not exist
 				country[parts[2]] = &countryType{addr: 0, long: parts[3]}
@@ -215,7 +292,7 @@ func WriteBIN(input string, output string, dbPackage string) {
 		}
 		if ispEnabled {
 			if lines == 1 {
-				if strings.Contains(parts[ispPosition[dbType]+1], "Broadcast RFC1700") {
+				if ipFamily == "4" || strings.Contains(parts[ispPosition[dbType]+1], "Broadcast RFC1700") {
 					ispCase = 4 // IPv4 case: no special handling required for the moment
 				} else {
 					ispCase = 6 // IPv6 case: need to manipulate some rows at the start due to differences in the original input CSV files (pure IPv4 & pure IPv6 vs. merged IP files)
@@ -272,26 +349,8 @@ func WriteBIN(input string, output string, dbPackage string) {
 			as[parts[asPosition[dbType]+1]] = 1
 		}
 
-		startNum := new(big.Int)
-		startNum, _ = startNum.SetString(parts[0], 10)
-
-		endNum := new(big.Int)
-		endNum, _ = endNum.SetString(parts[1], 10)
-
-		// need to detect if is IPv4 CSV and show error
-		// check line 10 because some IPv6 CSV has IPv4 ranges at the start
-		if lines == 10 {
-			endOfIPV4 := new(big.Int)
-			endOfIPV4, _ = endOfIPV4.SetString("4294967295", 10) // 255.255.255.255
-
-			if startNum.Cmp(endOfIPV4) <= 0 || endNum.Cmp(endOfIPV4) <= 0 {
-				fmt.Println("Please use IP2Location IPv6 CSV.")
-				return
-			}
-		}
-
-		var startIP net.IP
-		var endIP net.IP
+		startNum, startOK := parseUint128(parts[0])
+		endNum, endOK := parseUint128(parts[1])
 
 		if ispCase == 6 && ((lines >= 1 && lines <= 4) || strings.Contains(parts[ispPosition[dbType]+1], "Broadcast RFC1700")) { // special case when ISP field is present in IPv6 CSV
 			// First 4 lines treat as IPv6 (16 bytes)
@@ -299,16 +358,14 @@ func WriteBIN(input string, output string, dbPackage string) {
 			// Special case: Broadcast RFC1700 line convert to Ipv4 and insert under IPv4 section
 			if lines >= 1 && lines <= 4 {
 				// first 4 lines must treat as IPv6 to insert under IPv6 section
-				no2From, err := GetIPv6First2Octet(parts[0])
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
+				if !startOK {
+					return columnError("ip_from", newClassError(ErrIPNumber, "Unable to get first 2 octets."))
 				}
-				no2To, err := GetIPv6First2Octet(parts[1])
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
+				if !endOK {
+					return columnError("ip_to", newClassError(ErrIPNumber, "Unable to get first 2 octets."))
 				}
+				no2From := startNum.first2Octet()
+				no2To := endNum.first2Octet()
 				for no2 := no2From; no2 <= no2To; no2++ {
 					if _, ok := ipv6IndexRowMin[no2]; !ok { // if map key not exist
 						ipv6IndexRowMin[no2] = ipv6Count
@@ -322,37 +379,20 @@ func WriteBIN(input string, output string, dbPackage string) {
 					// need to manually insert another line for IPv4Map
 					isp["IPv4Map"] = 1
 
-					no2From, err := GetIPv6First2Octet("281470681743360")
-					if err != nil {
-						fmt.Println("Unable to get first 2 octets.")
-						return
-					}
-					no2To, err := GetIPv6First2Octet("281474976710655")
-					if err != nil {
-						fmt.Println("Unable to get first 2 octets.")
-						return
-					}
+					no2From := ipv4MappedStartNumber.first2Octet()
+					no2To := ipv4MappedEndNumber.first2Octet()
 					for no2 := no2From; no2 <= no2To; no2++ {
 						if _, ok := ipv6IndexRowMin[no2]; !ok { // if map key not exist
 							ipv6IndexRowMin[no2] = ipv6Count
 						}
 						ipv6IndexRowMax[no2] = ipv6Count
 					}
-					lastIPv6To = "281474976710655"
+					lastIPv6To = ipv4MappedEndNumber.String()
 					ipv6Count++
 				}
 			} else {
 				// Broadcast RFC1700 case where we need to insert into IPv4 section with different IP numbers
-				no2From, err := GetIPv4First2Octet("0")
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
-				}
-				no2To, err := GetIPv4First2Octet("16777215")
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
-				}
+				var no2From, no2To uint32 = 0, 16777215 >> 16 // 0.0.0.0 to 0.255.255.255
 				for no2 := no2From; no2 <= no2To; no2++ {
 					if _, ok := ipv4IndexRowMin[no2]; !ok { // if map key not exist
 						ipv4IndexRowMin[no2] = ipv4Count
@@ -363,58 +403,30 @@ func WriteBIN(input string, output string, dbPackage string) {
 				ipv4Count++
 			}
 		} else { // normal case where ISP field not present or is IPv4 CSV or rows not covered by the above criteria
-			if startIP, err = DecimalToIPv4(startNum); err != nil {
-				if startIP, err = DecimalToIPv6(startNum); err != nil {
-					fmt.Println("Decimal to IP conversion failed.")
-					return
-				}
+			if !startOK {
+				return columnError("ip_from", newClassError(ErrIPNumber, "Decimal to IP conversion failed."))
 			}
-			startIPStr := startIP.String() // this will return plain IPv4 from IPv4-mapped IPv6 since we only want plain IPv4
-
-			if endIP, err = DecimalToIPv4(endNum); err != nil {
-				if endIP, err = DecimalToIPv6(endNum); err != nil {
-					fmt.Println("Decimal to IP conversion failed.")
-					return
-				}
+			if !endOK {
+				return columnError("ip_to", newClassError(ErrIPNumber, "Decimal to IP conversion failed."))
 			}
-			endIPStr := endIP.String() // this will return plain IPv4 from IPv4-mapped IPv6 since we only want plain IPv4
 
-			var newStartNum *big.Int
-			var newEndNum *big.Int
-			startIPType := 0
-			if IsIPv4(startIPStr) {
+			// IPv4-mapped IPv6 is taken as plain IPv4 since we only want plain IPv4
+			startIPType := 6
+			startIPv4, ok := startNum.ipv4()
+			if ok {
 				startIPType = 4
-				if newStartNum, err = IPv4ToDecimal(startIPStr); err != nil {
-					fmt.Println("IP to decimal conversion failed.")
-					return
-				}
-				parts[0] = newStartNum.String()
-			} else if IsIPv6(startIPStr) {
-				startIPType = 6
+				parts[0] = strconv.FormatUint(uint64(startIPv4), 10)
 			}
-			endIPType := 0
-			if IsIPv4(endIPStr) {
+			endIPType := 6
+			endIPv4, ok := endNum.ipv4()
+			if ok {
 				endIPType = 4
-				if newEndNum, err = IPv4ToDecimal(endIPStr); err != nil {
-					fmt.Println("IP to decimal conversion failed.")
-					return
-				}
-				parts[1] = newEndNum.String()
-			} else if IsIPv6(endIPStr) {
-				endIPType = 6
+				parts[1] = strconv.FormatUint(uint64(endIPv4), 10)
 			}
 
 			if startIPType == 4 && endIPType == 4 {
-				no2From, err := GetIPv4First2Octet(parts[0])
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
-				}
-				no2To, err := GetIPv4First2Octet(parts[1])
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
-				}
+				no2From := startIPv4 >> 16
+				no2To := endIPv4 >> 16
 				for no2 := no2From; no2 <= no2To; no2++ {
 					if _, ok := ipv4IndexRowMin[no2]; !ok { // if map key not exist
 						ipv4IndexRowMin[no2] = ipv4Count
@@ -424,16 +436,8 @@ func WriteBIN(input string, output string, dbPackage string) {
 				lastIPv4To = parts[1]
 				ipv4Count++
 			} else if startIPType == 6 && endIPType == 6 {
-				no2From, err := GetIPv6First2Octet(parts[0])
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
-				}
-				no2To, err := GetIPv6First2Octet(parts[1])
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
-				}
+				no2From := startNum.first2Octet()
+				no2To := endNum.first2Octet()
 				for no2 := no2From; no2 <= no2To; no2++ {
 					if _, ok := ipv6IndexRowMin[no2]; !ok { // if map key not exist
 						ipv6IndexRowMin[no2] = ipv6Count
@@ -444,36 +448,20 @@ func WriteBIN(input string, output string, dbPackage string) {
 				ipv6Count++
 			} else if startIPType == 4 && endIPType == 6 { // special boundary case where we need to split the range into IPv4 only and IPv6 only
 				// IPv4 range
-				no2From, err := GetIPv4First2Octet(parts[0])
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
-				}
-				no2To, err := GetIPv4First2Octet(maxIPv4Range.String()) // 255.255.255.255
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
-				}
+				no2From := startIPv4 >> 16
+				no2To := uint32(maxIPv4Number.lo >> 16) // 255.255.255.255
 				for no2 := no2From; no2 <= no2To; no2++ {
 					if _, ok := ipv4IndexRowMin[no2]; !ok { // if map key not exist
 						ipv4IndexRowMin[no2] = ipv4Count
 					}
 					ipv4IndexRowMax[no2] = ipv4Count
 				}
-				lastIPv4To = maxIPv4Range.String()
+				lastIPv4To = maxIPv4Number.String()
 				ipv4Count++
 
 				// IPv6 range
-				no2From2, err := GetIPv6First2Octet(maxIPv4RangePlusOne.String())
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
-				}
-				no2To2, err := GetIPv6First2Octet(parts[1])
-				if err != nil {
-					fmt.Println("Unable to get first 2 octets.")
-					return
-				}
+				no2From2 := maxIPv4Number.addOne().first2Octet()
+				no2To2 := endNum.first2Octet()
 				for no2 := no2From2; no2 <= no2To2; no2++ {
 					if _, ok := ipv6IndexRowMin[no2]; !ok { // if map key not exist
 						ipv6IndexRowMin[no2] = ipv6Count
@@ -486,13 +474,14 @@ func WriteBIN(input string, output string, dbPackage string) {
 		}
 	}
 
+	if ipFamily == "6" && lastIPv6To == "" {
+		return ipFamilyError("6")
+	}
 	if lastIPv4To != "4294967295" {
-		fmt.Printf("The last IP address in the CSV file is %s not 4294967295.\n", lastIPv4To)
-		return
+		return newClassError(ErrNotContiguous, "The last IP address in the CSV file is %s not 4294967295.", lastIPv4To)
 	}
 	if lastIPv6To != "" && lastIPv6To != "340282366920938463463374607431768211455" { // blank means IPv4-only CSV
-		fmt.Printf("The last IP address in the CSV file is %s not 340282366920938463463374607431768211455.\n", lastIPv6To)
-		return
+		return newClassError(ErrNotContiguous, "The last IP address in the CSV file is %s not 340282366920938463463374607431768211455.", lastIPv6To)
 	}
 
 	ipv4Count++
@@ -504,6 +493,9 @@ func WriteBIN(input string, output string, dbPackage string) {
 	addr := ipv6Base + ipv6Count*longSize*(uint32(dbColl)+3) // IPv6 address range is 4 bytes vs 1 byte in IPv4
 
 	if countryEnabled {
+		if _, ok := country["-"]; !ok { // needed for the ending record
+			country["-"] = &countryType{addr: 0, long: "-"}
+		}
 		countrySorted = GetSortedKeysCountry(country)
 		for _, v := range countrySorted {
 			country[v].addr = addr
@@ -679,19 +671,10 @@ func WriteBIN(input string, output string, dbPackage string) {
 		}
 	}
 
-	var inFile2 *os.File
-	if inFile2, err = os.Open(input); err != nil {
-		fmt.Printf("Invalid input file %v.\n", input)
-		return
-	}
-	defer inFile2.Close()
+	dbFileSize = addr // the strings are the end of the file
 
-	var outFile *os.File
-	if outFile, err = os.Create(output); err != nil {
-		fmt.Printf("Could not create output file %v.\n", output)
-		return
-	}
-	defer outFile.Close()
+	counter := &countingWriter{w: out}
+	outFile := bufio.NewWriterSize(counter, 65536) // keeps the first write error until the flush
 
 	var header = []any{
 		dbType,
@@ -730,21 +713,21 @@ func WriteBIN(input string, output string, dbPackage string) {
 		WriteMe(outFile, ipv6IndexRowMin[v])
 		WriteMe(outFile, ipv6IndexRowMax[v])
 	}
-
-	p := Tell(outFile)
-	if p != 1048640 {
-		fmt.Println("Index out of range.")
-		return
+	if len(sortedUint) == 0 { // IPv4 CSV still needs the empty IPv6 index
+		WriteMe(outFile, make([]byte, ipv4Base-ipv6IndexBase))
 	}
 
-	var rdr2 *csv.Reader
-	inFileBuffered2 := bufio.NewReaderSize(inFile2, 65536)
-
-	csvRdr2 := csv.NewReader(inFileBuffered2)
-	csvRdr2.Comma = delim
-	csvRdr2.LazyQuotes = false
+	if err = outFile.Flush(); err != nil {
+		return &IOError{Op: "Writing to output file failed.", Err: err}
+	}
+	if counter.n != int64(ipv4Base) {
+		return newClassError(ErrInvalidData, "Index out of range.")
+	}
 
-	rdr2 = csvRdr2
+	var rdr2 csvRecordReader
+	if rdr2, err = records.Reader(); err != nil { // second pass to write the rows
+		return err
+	}
 
 	lines = 0
 
@@ -754,43 +737,25 @@ func WriteBIN(input string, output string, dbPackage string) {
 	var outputV4Ending = 0 // force output the ending row for IPv4 in ISP IPv6 case
 
 	for {
+		csvRecord = nil
 		parts, err := rdr2.Read()
 
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			fmt.Println("Unable to read input file.")
-			return
+			return err
 		}
+		csvLine, _ = rdr2.FieldPos(0)
+		csvRecord = append([]string(nil), parts...)
 
-		if parts[0] == "0" && parts[1] == "281470681743359" {
-			continue // just skip the uncompressed line at the start of LITE
-		}
-		if parts[0] == "281470681743360" && parts[1] == "1470698520575" {
-			parts[0] = "0" // compress the 2 lines at the start of LITE into 1 line
-		}
-
-		if parts[0] == "281474439839744" && parts[1] == "281474976710655" {
-			continue // just skip the uncompressed line in the LITE for the IPv4/IPv6 boundary
-		}
-		if parts[0] == "281474976710656" && parts[1] == "42540528726795050063891204319802818559" {
-			parts[0] = "281474439839744" // compress the 2 lines in the LITE for the IPv4/IPv6 boundary into 1 line
+		if !rewriteLITERecord(parts) {
+			continue
 		}
 
 		lines++
 
-		startNum := new(big.Int)
-		startNum, _ = startNum.SetString(parts[0], 10)
-
-		endNum := new(big.Int)
-		endNum, _ = endNum.SetString(parts[1], 10)
-
-		var startIP net.IP
-		var endIP net.IP
-
-		if parts[2] == "UK" {
-			parts[2] = "GB"
-		}
+		startNum, startOK := parseUint128(parts[0])
+		endNum, endOK := parseUint128(parts[1])
 
 		var row = []any{}
 
@@ -798,13 +763,10 @@ func WriteBIN(input string, output string, dbPackage string) {
 			outputV4Ending = 1
 			if lines >= 1 && lines <= 4 {
 				// These 4 lines should be IPv6 even though first 3 lines are showing IPv4
-				v6Bytes, err := ForceAsIPv6(parts[0])
-				if err != nil {
-					fmt.Println("IP to bytes conversion failed.")
-					return
+				if !startOK {
+					return columnError("ip_from", newClassError(ErrIPNumber, "IP to bytes conversion failed."))
 				}
-				ReverseBytes(v6Bytes)
-				row6 = append(row6, v6Bytes)
+				row6 = append(row6, startNum.littleEndian())
 				if countryEnabled {
 					row6 = append(row6, country[parts[2]].addr)
 				}
@@ -817,16 +779,14 @@ func WriteBIN(input string, output string, dbPackage string) {
 				if latitudeEnabled {
 					lat, err := strconv.ParseFloat(parts[latitudePosition[dbType]+1], 64)
 					if err != nil {
-						fmt.Println("String to float conversion failed.")
-						return
+						return columnError("latitude", newClassError(ErrInvalidData, "String to float conversion failed."))
 					}
 					row6 = append(row6, float32(lat))
 				}
 				if longitudeEnabled {
 					long, err := strconv.ParseFloat(parts[longitudePosition[dbType]+1], 64)
 					if err != nil {
-						fmt.Println("String to float conversion failed.")
-						return
+						return columnError("longitude", newClassError(ErrInvalidData, "String to float conversion failed."))
 					}
 					row6 = append(row6, float32(long))
 				}
@@ -890,13 +850,7 @@ func WriteBIN(input string, output string, dbPackage string) {
 
 				if lines == 4 {
 					// need to manually insert another line for IPv4Map (should be IPv6)
-					v6Bytes, err := ForceAsIPv6("281470681743360")
-					if err != nil {
-						fmt.Println("IP to bytes conversion failed.")
-						return
-					}
-					ReverseBytes(v6Bytes)
-					row6 = append(row6, v6Bytes)
+					row6 = append(row6, ipv4MappedStartNumber.littleEndian())
 					if countryEnabled {
 						row6 = append(row6, country["-"].addr)
 					}
@@ -909,16 +863,14 @@ func WriteBIN(input string, output string, dbPackage string) {
 					if latitudeEnabled {
 						lat, err := strconv.ParseFloat("0.000000", 64)
 						if err != nil {
-							fmt.Println("String to float conversion failed.")
-							return
+							return newClassError(ErrInvalidData, "String to float conversion failed.")
 						}
 						row6 = append(row6, float32(lat))
 					}
 					if longitudeEnabled {
 						long, err := strconv.ParseFloat("0.000000", 64)
 						if err != nil {
-							fmt.Println("String to float conversion failed.")
-							return
+							return newClassError(ErrInvalidData, "String to float conversion failed.")
 						}
 						row6 = append(row6, float32(long))
 					}
@@ -982,13 +934,7 @@ func WriteBIN(input string, output string, dbPackage string) {
 				}
 			} else {
 				// Broadcast RFC1700 case where we need to insert into IPv4 section with different IPv4 IP numbers
-				v4Bytes, err := ForceAsIPv4("0")
-				if err != nil {
-					fmt.Println("IP to bytes conversion failed.")
-					return
-				}
-				ReverseBytes(v4Bytes)
-				row = append(row, v4Bytes)
+				row = append(row, ipv4LittleEndian(0))
 				if countryEnabled {
 					row = append(row, country[parts[2]].addr)
 				}
@@ -1001,16 +947,14 @@ func WriteBIN(input string, output string, dbPackage string) {
 				if latitudeEnabled {
 					lat, err := strconv.ParseFloat(parts[latitudePosition[dbType]+1], 64)
 					if err != nil {
-						fmt.Println("String to float conversion failed.")
-						return
+						return columnError("latitude", newClassError(ErrInvalidData, "String to float conversion failed."))
 					}
 					row = append(row, float32(lat))
 				}
 				if longitudeEnabled {
 					long, err := strconv.ParseFloat(parts[longitudePosition[dbType]+1], 64)
 					if err != nil {
-						fmt.Println("String to float conversion failed.")
-						return
+						return columnError("longitude", newClassError(ErrInvalidData, "String to float conversion failed."))
 					}
 					row = append(row, float32(long))
 				}
@@ -1073,69 +1017,37 @@ func WriteBIN(input string, output string, dbPackage string) {
 				}
 			}
 		} else { // normal case where ISP field not present or is IPv4 CSV or rows not covered by the above criteria
-			if startIP, err = DecimalToIPv4(startNum); err != nil {
-				if startIP, err = DecimalToIPv6(startNum); err != nil {
-					fmt.Println("Decimal to IP conversion failed.")
-					return
-				}
+			if !startOK {
+				return columnError("ip_from", newClassError(ErrIPNumber, "Decimal to IP conversion failed."))
 			}
-			startIPStr := startIP.String() // this will return plain IPv4 from IPv4-mapped IPv6 since we only want plain IPv4
-
-			if endIP, err = DecimalToIPv4(endNum); err != nil {
-				if endIP, err = DecimalToIPv6(endNum); err != nil {
-					fmt.Println("Decimal to IP conversion failed.")
-					return
-				}
+			if !endOK {
+				return columnError("ip_to", newClassError(ErrIPNumber, "Decimal to IP conversion failed."))
 			}
-			endIPStr := endIP.String() // this will return plain IPv4 from IPv4-mapped IPv6 since we only want plain IPv4
 
-			var newStartNum *big.Int
-			var newEndNum *big.Int
-			startIPType := 0
-			if IsIPv4(startIPStr) {
+			// IPv4-mapped IPv6 is taken as plain IPv4 since we only want plain IPv4
+			startIPType := 6
+			startIPv4, ok := startNum.ipv4()
+			if ok {
 				startIPType = 4
-				if newStartNum, err = IPv4ToDecimal(startIPStr); err != nil {
-					fmt.Println("IP to decimal conversion failed.")
-					return
-				}
-				parts[0] = newStartNum.String()
-			} else if IsIPv6(startIPStr) {
-				startIPType = 6
+				parts[0] = strconv.FormatUint(uint64(startIPv4), 10)
 			}
-			endIPType := 0
-			if IsIPv4(endIPStr) {
+			endIPType := 6
+			endIPv4, ok := endNum.ipv4()
+			if ok {
 				endIPType = 4
-				if newEndNum, err = IPv4ToDecimal(endIPStr); err != nil {
-					fmt.Println("IP to decimal conversion failed.")
-					return
-				}
-				parts[1] = newEndNum.String()
-			} else if IsIPv6(endIPStr) {
-				endIPType = 6
+				parts[1] = strconv.FormatUint(uint64(endIPv4), 10)
 			}
 
 			ipv4boundary := false
 
 			if startIPType == 4 && endIPType == 4 {
-				v4Bytes, err := IPv4ToBytes(startIPStr)
-				if err != nil {
-					fmt.Println("IP to bytes conversion failed.")
-					return
-				}
-				ReverseBytes(v4Bytes)
-				row = append(row, v4Bytes)
+				row = append(row, ipv4LittleEndian(startIPv4))
 			} else if startIPType == 6 && endIPType == 6 {
 				// assume the ISP cases only hit this case
 				if outputV4Ending == 1 {
 					outputV4Ending = 0
 
-					v4Bytes, err := IPv4ToBytes("255.255.255.255")
-					if err != nil {
-						fmt.Println("IP to bytes conversion failed.")
-						return
-					}
-					ReverseBytes(v4Bytes)
-					row4 = append(row4, v4Bytes)
+					row4 = append(row4, ipv4LittleEndian(uint32(maxIPv4Number.lo)))
 
 					if countryEnabled {
 						row4 = append(row4, country["-"].addr)
@@ -1225,22 +1137,10 @@ func WriteBIN(input string, output string, dbPackage string) {
 					row6 = row6[:0] // reset row6
 				}
 
-				v6Bytes, err := IPv6ToBytes(startIPStr)
-				if err != nil {
-					fmt.Println("IP to bytes conversion failed.")
-					return
-				}
-				ReverseBytes(v6Bytes)
-				row = append(row, v6Bytes)
+				row = append(row, startNum.littleEndian())
 			} else if startIPType == 4 && endIPType == 6 { // special boundary case where we need to split the range into IPv4 only and IPv6 only
 				ipv4boundary = true
-				v4Bytes, err := IPv4ToBytes(startIPStr)
-				if err != nil {
-					fmt.Println("IP to bytes conversion failed.")
-					return
-				}
-				ReverseBytes(v4Bytes)
-				row = append(row, v4Bytes)
+				row = append(row, ipv4LittleEndian(startIPv4))
 			}
 
 			if countryEnabled {
@@ -1255,16 +1155,14 @@ func WriteBIN(input string, output string, dbPackage string) {
 			if latitudeEnabled {
 				lat, err := strconv.ParseFloat(parts[latitudePosition[dbType]+1], 64)
 				if err != nil {
-					fmt.Println("String to float conversion failed.")
-					return
+					return columnError("latitude", newClassError(ErrInvalidData, "String to float conversion failed."))
 				}
 				row = append(row, float32(lat))
 			}
 			if longitudeEnabled {
 				long, err := strconv.ParseFloat(parts[longitudePosition[dbType]+1], 64)
 				if err != nil {
-					fmt.Println("String to float conversion failed.")
-					return
+					return columnError("longitude", newClassError(ErrInvalidData, "String to float conversion failed."))
 				}
 				row = append(row, float32(long))
 			}
@@ -1329,18 +1227,8 @@ func WriteBIN(input string, output string, dbPackage string) {
 			if ipv4boundary {
 				ipv4boundary = false
 
-				var err error
-				var v6Bytes []byte
-
-				var v4Bytes []byte
 				// IPv4 part
-				v4Bytes, err = IPv4ToBytes("255.255.255.255")
-				if err != nil {
-					fmt.Println("IP to bytes conversion failed.")
-					return
-				}
-				ReverseBytes(v4Bytes)
-				row = append(row, v4Bytes)
+				row = append(row, ipv4LittleEndian(uint32(maxIPv4Number.lo)))
 
 				if countryEnabled {
 					row = append(row, country["-"].addr)
@@ -1416,13 +1304,7 @@ func WriteBIN(input string, output string, dbPackage string) {
 				}
 
 				// IPv6 part
-				v6Bytes, err = IPv6ToBytes("::1:0:0")
-				if err != nil {
-					fmt.Println("IP to bytes conversion failed.")
-					return
-				}
-				ReverseBytes(v6Bytes)
-				row = append(row, v6Bytes)
+				row = append(row, maxIPv4Number.addOne().littleEndian()) // ::1:0:0
 
 				if countryEnabled {
 					row = append(row, country["-"].addr)
@@ -1508,21 +1390,9 @@ func WriteBIN(input string, output string, dbPackage string) {
 	// dealing with ending record
 	var row = []any{}
 	if lastIPv6To != "" { // output ending record for IPv6 range if is IPv6 CSV
-		v6Bytes, err := IPv6ToBytes("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
-		if err != nil {
-			fmt.Println("IP to bytes conversion failed.")
-			return
-		}
-		ReverseBytes(v6Bytes)
-		row = append(row, v6Bytes)
+		row = append(row, maxIPv6Number.littleEndian())
 	} else { // only IPv4 CSV so need to output ending record for IPv4 range
-		v4Bytes, err := IPv4ToBytes("255.255.255.255")
-		if err != nil {
-			fmt.Println("IP to bytes conversion failed.")
-			return
-		}
-		ReverseBytes(v4Bytes)
-		row = append(row, v4Bytes)
+		row = append(row, ipv4LittleEndian(uint32(maxIPv4Number.lo)))
 	}
 
 	if countryEnabled {
@@ -1741,41 +1611,104 @@ func WriteBIN(input string, output string, dbPackage string) {
 		}
 	}
 
-	if err = outFile.Sync(); err != nil {
-		fmt.Println("Error flushing to disk.")
-		return
+	if err = outFile.Flush(); err != nil {
+		return &IOError{Op: "Writing to output file failed.", Err: err}
 	}
-	var fi os.FileInfo
-	if fi, err = outFile.Stat(); err != nil {
-		fmt.Println("Unable to get file size.")
-		return
+
+	if counter.n != int64(dbFileSize) {
+		return newClassError(ErrInvalidData, "File size is %d bytes instead of %d bytes.", counter.n, dbFileSize)
+	}
+
+	if err = SyncOutput(out); err != nil {
+		return &IOError{Op: "Error flushing to disk.", Err: err}
 	}
-	fileSize := fi.Size() // int64
-	dbFileSize = uint32(fileSize)
+	return nil
+}
+
+// DetectCSVIPFamily returns "4" if all the IP numbers fit in IPv4 otherwise "6". The CSV is read from the
+// start and seeked back to the start after.
+func DetectCSVIPFamily(in io.ReadSeeker) (string, error) {
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return "", &IOError{Op: "Unable to read input file.", Err: err}
+	}
+	defer in.Seek(0, io.SeekStart)
+
+	csvRdr := csv.NewReader(bufio.NewReaderSize(in, 65536))
+	csvRdr.FieldsPerRecord = -1
+	csvRdr.ReuseRecord = true
+
+	for {
+		parts, err := csvRdr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", csvReadError(err)
+		} else if len(parts) < 2 {
+			line, _ := csvRdr.FieldPos(0)
+			return "", newClassError(ErrColumnCount, "Line %d: CSV should have the IP from and IP to columns.", line)
+		}
 
-	if _, err = outFile.Seek(31, os.SEEK_SET); err != nil {
-		fmt.Println("Unable to seek.")
-		return
+		// IPv6 CSV can have IPv4 numbers at the start so only stop when we see a bigger number
+		for _, v := range parts[:2] {
+			num, ok := parseUint128(v)
+			if !ok {
+				return "", &IPNumberError{Value: v}
+			}
+			if num.cmp(maxIPv4Number) > 0 {
+				return "6", nil
+			}
+		}
 	}
+	return "4", nil
+}
+
+// ipFamilyError returns the error for an IP2Location CSV of the other IP family
+func ipFamilyError(ipFamily string) error {
+	return newClassError(ErrIPFamily, "Please use IP2Location IPv%s CSV.", ipFamily)
+}
 
-	WriteMe(outFile, dbFileSize)
+// nextIPRange checks that the CSV record has valid IP numbers and starts at next, with the IP numbers of the record
+// already parsed. Returns the cursor after the range for the next record.
+func nextIPRange(parts []string, ips csvIPRange, next ipCursor) (ipCursor, error) {
+	start, end := ips.start, ips.end
+	if !ips.startOK {
+		return next, columnError("ip_from", &IPNumberError{Value: parts[0]})
+	}
+	if !ips.endOK || end.cmp(start) < 0 {
+		return next, columnError("ip_to", &IPNumberError{Value: parts[1]})
+	}
+	if next.end || start != next.next {
+		return next, columnError("ip_from", &RangeError{Start: parts[0], Expected: next.String()})
+	}
+	next.advance(end)
+	return next, nil
 }
 
-func WriteMe(out *os.File, data any) {
+// WriteMe writes the value in little endian, strings are written as the bytes without the length
+func WriteMe(out io.Writer, data any) error {
 	if str, ok := data.(string); ok { // check that is string type
-		if err := binary.Write(out, binary.LittleEndian, []byte(str)); err != nil {
-			fmt.Printf("Write failed %v\n", err)
-		}
-	} else if err := binary.Write(out, binary.LittleEndian, data); err != nil {
-		fmt.Printf("Write failed %v\n", err)
+		return binary.Write(out, binary.LittleEndian, []byte(str))
 	}
+	return binary.Write(out, binary.LittleEndian, data)
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
-func Tell(out *os.File) uint32 {
-	if pos, err := out.Seek(0, os.SEEK_CUR); err != nil {
-		fmt.Println("Tell failed.")
-		return 0
-	} else {
-		return uint32(pos)
+// Tell returns the current position of the output
+func Tell(out io.Seeker) (uint32, error) {
+	pos, err := out.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, &IOError{Op: "Tell failed.", Err: err}
 	}
+	return uint32(pos), nil
 }
@@ -0,0 +1,78 @@
+package convert
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDate is the date of the database in the golden files of testdata
+var testDate = time.Date(2021, 1, 20, 0, 0, 0, 0, time.UTC)
+
+// readTestdata returns the file in testdata, decompressed if it is gzipped
+func readTestdata(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasSuffix(name, ".gz") {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return data
+}
+
+// testConversions are the CSV conversions of the testdata files for the tests that compare the output with and
+// without a feature
+var testConversions = []struct {
+	name    string
+	file    string
+	convert func(in io.Reader, out io.Writer, jobs int) error
+}{
+	{"csv2bin", "db5.csv", func(in io.Reader, out io.Writer, jobs int) error {
+		return WriteBIN(in, out, BINOptions{Package: 5, Jobs: jobs, Date: testDate})
+	}},
+	{"csv2bin PX2", "px2.csv", func(in io.Reader, out io.Writer, jobs int) error {
+		return WriteProxyBIN(in, out, BINOptions{Package: 2, Jobs: jobs, Date: testDate})
+	}},
+	{"csv2mmdb DB5", "db5.csv", func(in io.Reader, out io.Writer, jobs int) error {
+		return ConvertCSV2MMDB(in, out, MMDBOptions{Package: 5, Jobs: jobs, Date: testDate})
+	}},
+	{"csv2mmdb proxy", "px2.csv", func(in io.Reader, out io.Writer, jobs int) error {
+		return ConvertCSV2MMDB(in, out, MMDBOptions{Type: "proxy", Jobs: jobs, Date: testDate})
+	}},
+}
+
+// checkBytes fails the test at the first byte that differs
+func checkBytes(t testing.TB, name string, got []byte, want []byte) {
+	t.Helper()
+	if bytes.Equal(got, want) {
+		return
+	}
+	i := 0
+	for i < len(got) && i < len(want) && got[i] == want[i] {
+		i++
+	}
+	t.Errorf("%s differs at byte %d, got %d bytes, want %d bytes", name, i, len(got), len(want))
+}
+
+// TestWriteBINGolden checks WriteBIN against the BIN written by csv2bin before the uint128 helpers and the single
+// pass over the CSV
+func TestWriteBINGolden(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteBIN(bytes.NewReader(readTestdata(t, "db5.csv")), &out, BINOptions{Package: 5, Date: testDate}); err != nil {
+		t.Fatal(err)
+	}
+	checkBytes(t, "BIN", out.Bytes(), readTestdata(t, "db5.bin.gz"))
+}
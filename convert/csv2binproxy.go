@@ -0,0 +1,311 @@
+package convert
+
+import (
+	"io"
+)
+
+var pxProxyTypePosition = [13]uint8{0, 0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2}
+var pxCountryPosition = [13]uint8{0, 2, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3}
+var pxRegionPosition = [13]uint8{0, 0, 0, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4}
+var pxCityPosition = [13]uint8{0, 0, 0, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5}
+var pxISPPosition = [13]uint8{0, 0, 0, 0, 6, 6, 6, 6, 6, 6, 6, 6, 6}
+var pxDomainPosition = [13]uint8{0, 0, 0, 0, 0, 7, 7, 7, 7, 7, 7, 7, 7}
+var pxUsageTypePosition = [13]uint8{0, 0, 0, 0, 0, 0, 8, 8, 8, 8, 8, 8, 8}
+var pxASNPosition = [13]uint8{0, 0, 0, 0, 0, 0, 0, 9, 9, 9, 9, 9, 9}
+var pxASPosition = [13]uint8{0, 0, 0, 0, 0, 0, 0, 10, 10, 10, 10, 10, 10}
+var pxLastSeenPosition = [13]uint8{0, 0, 0, 0, 0, 0, 0, 0, 11, 11, 11, 11, 11}
+var pxThreatPosition = [13]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 12, 12, 12, 12}
+var pxProviderPosition = [13]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 13, 13}
+var pxFraudScorePosition = [13]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 14}
+var pxColumnSize = [13]uint8{0, 2, 3, 5, 6, 7, 8, 10, 11, 12, 12, 13, 14}
+
+// same order as the fields are written by WriteRangesBIN
+var ip2proxyColumns = []binColumn{
+	{"proxy_type", pxProxyTypePosition[:], columnString},
+	{"country", pxCountryPosition[:], columnCountry},
+	{"region", pxRegionPosition[:], columnString},
+	{"city", pxCityPosition[:], columnString},
+	{"isp", pxISPPosition[:], columnString},
+	{"domain", pxDomainPosition[:], columnString},
+	{"usage_type", pxUsageTypePosition[:], columnString},
+	{"asn", pxASNPosition[:], columnString},
+	{"as", pxASPosition[:], columnString},
+	{"last_seen", pxLastSeenPosition[:], columnString},
+	{"threat", pxThreatPosition[:], columnString},
+	{"provider", pxProviderPosition[:], columnString},
+	{"fraud_score", pxFraudScorePosition[:], columnString},
+}
+
+// WriteProxyBIN converts the IP2Proxy CSV into IP2Proxy BIN. Unlike the IP2Location CSV, the IP2Proxy CSV only
+// contains the proxy ranges so the gaps are filled with "-" rows. The CSV is only read once, the records are kept
+// in a temp file for the passes over them, so neither the input nor the output needs to be seekable.
+func WriteProxyBIN(in io.Reader, out io.Writer, opts BINOptions) error {
+	var err error
+
+	dbType := opts.Package
+	if dbType < 1 || dbType > 12 {
+		return newClassError(ErrOption, "Invalid PX package.")
+	}
+	if _, _, _, err = binDate(opts.Date); err != nil {
+		return err
+	}
+	dbColl := pxColumnSize[dbType]
+
+	// the CSV is parsed once into the record store, the passes of WriteRangesBIN read the records from there
+	records, err := newRecordStore()
+	if err != nil {
+		return err
+	}
+	defer records.Close()
+
+	csvRdr, stopCSV := newCSVReader(in, false, opts.Jobs, opts.OnError != nil) // column count is checked below for the PX package
+	defer stopCSV()
+	storeRdr := &proxyStoreReader{rdr: csvRdr, records: records, ipFamily: opts.IPFamily}
+
+	// the records are checked in the IPv6 layout, which has the same errors as the IPv4 layout for the IPv4 CSV,
+	// so the bad records are all passed to the handler in this pass and in the order of the lines
+	handler := &recordErrorHandler{onError: opts.OnError}
+	if err = walkProxyCSV(storeRdr, dbColl, "6", handler, nil); err != nil {
+		return err
+	}
+
+	ipFamily := opts.IPFamily
+	if ipFamily == "" {
+		ipFamily = storeRdr.detectedFamily()
+	} else if ipFamily != storeRdr.detectedFamily() {
+		return proxyIPFamilyError(ipFamily)
+	}
+
+	return WriteRangesBIN(out, 2, dbType, ipFamily == "6", opts.Date, func(fn binRangeFunc) error {
+		rdr, err := records.Reader()
+		if err != nil {
+			return err
+		}
+		return walkProxyCSV(rdr, dbColl, ipFamily, handler, func(ipv6 bool, start uint128, end uint128, parts []string) error {
+			return fn(ipv6, start, end, parts[2:])
+		})
+	})
+}
+
+// proxyIPFamilyError returns the error for an IP2Proxy CSV of the other IP family
+func proxyIPFamilyError(ipFamily string) error {
+	return newClassError(ErrIPFamily, "Please use IP2Proxy IPv%s CSV.", ipFamily)
+}
+
+// proxyStoreReader reads the IP2Proxy CSV for WriteProxyBIN and keeps the records in the record store. It finds
+// the IP family of the CSV and stops at the first IPv6 record if the IPv4 CSV is wanted.
+type proxyStoreReader struct {
+	rdr      csvRecordReader
+	records  *recordStore
+	ipFamily string
+	ipv6     bool // a record has IP numbers past IPv4, even if left out
+}
+
+func (r *proxyStoreReader) Read() ([]string, error) {
+	parts, err := r.rdr.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, csvReadError(err)
+	}
+	ips := recordIPRange(r.rdr, parts)
+	if ips.hasIPv6Number() {
+		if r.ipFamily == "4" {
+			return nil, proxyIPFamilyError(r.ipFamily) // not a bad record, the whole CSV is the wrong one
+		}
+		r.ipv6 = true
+	}
+	line, _ := r.rdr.FieldPos(0)
+	if err = r.records.Write(line, parts); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+func (r *proxyStoreReader) FieldPos(field int) (int, int) {
+	return r.rdr.FieldPos(field)
+}
+
+// detectedFamily returns "6" if a record has IP numbers past IPv4 otherwise "4"
+func (r *proxyStoreReader) detectedFamily() string {
+	if r.ipv6 {
+		return "6"
+	}
+	return "4"
+}
+
+// EmptyCSVRecord returns a CSV record with only "-" for the DB column count
+func EmptyCSVRecord(dbColl uint8) []string {
+	parts := make([]string, int(dbColl)+2)
+	for i := range parts {
+		parts[i] = "-"
+	}
+	return parts
+}
+
+// walkProxyCSV calls fn with every range in the IP2Proxy CSV records and fills the gaps with "-" records.
+// IPv4-mapped IPv6 ranges are converted to IPv4 and all the IPv4 ranges come before the IPv6 ranges. The errors of
+// the reader are already those of the converters and the bad records are passed to the handler, the records left
+// out become gaps. fn is nil to only check the records.
+func walkProxyCSV(csvRdr csvRecordReader, dbColl uint8, ipFamily string, handler *recordErrorHandler, fn func(ipv6 bool, start uint128, end uint128, parts []string) error) error {
+	var err error
+	checkOnly := fn == nil
+	if checkOnly {
+		fn = func(ipv6 bool, start uint128, end uint128, parts []string) error {
+			return nil
+		}
+	}
+
+	type proxyRange struct {
+		start uint128
+		end   uint128
+		parts []string
+	}
+
+	empty := EmptyCSVRecord(dbColl)
+	var next4, next6 ipCursor
+	ipv4Done := false
+	lowIPv6 := []proxyRange{} // IPv6 ranges below the IPv4-mapped range need to wait until IPv4 is done
+
+	addIPv4 := func(start uint128, end uint128, parts []string) error {
+		if next4.before(start) {
+			return columnError("ip_from", newClassError(ErrNotContiguous, "IP ranges in the CSV file are not in order."))
+		}
+		if start != next4.next {
+			if err := fn(false, next4.next, start.subOne(), empty); err != nil {
+				return err
+			}
+		}
+		next4.advance(end)
+		return fn(false, start, end, parts)
+	}
+	emitIPv6 := func(start uint128, end uint128, parts []string) error {
+		if !ipv4Done && !checkOnly {
+			lowIPv6 = append(lowIPv6, proxyRange{start, end, parts})
+			return nil
+		}
+		return fn(true, start, end, parts)
+	}
+	addIPv6 := func(start uint128, end uint128, parts []string) error {
+		if next6.before(start) {
+			return columnError("ip_from", newClassError(ErrNotContiguous, "IP ranges in the CSV file are not in order."))
+		}
+		if start != next6.next {
+			if err := emitIPv6(next6.next, start.subOne(), empty); err != nil {
+				return err
+			}
+		}
+		next6.advance(end)
+		return emitIPv6(start, end, parts)
+	}
+	finishIPv4 := func() error {
+		ipv4Done = true
+		if !next4.before(maxIPv4Number) {
+			if err := fn(false, next4.next, maxIPv4Number, empty); err != nil {
+				return err
+			}
+		}
+		if next6.next.cmp(ipv4MappedStartNumber) < 0 {
+			lowIPv6 = append(lowIPv6, proxyRange{next6.next, ipv4MappedStartNumber.subOne(), empty})
+		}
+		for _, v := range lowIPv6 {
+			if err := fn(true, v.start, v.end, v.parts); err != nil {
+				return err
+			}
+		}
+		lowIPv6 = nil
+		next6 = ipCursor{next: ipv4MappedEndNumber.addOne()} // IPv6 section skips the IPv4-mapped range
+		return nil
+	}
+
+	addRecord := func(parts []string) error {
+		if len(parts) != int(dbColl)+2 {
+			return &ColumnCountError{Package: "IP2Proxy", Expected: []int{int(dbColl) + 2}, Found: len(parts)}
+		}
+
+		ips := recordIPRange(csvRdr, parts)
+		start, end := ips.start, ips.end
+		if !ips.startOK {
+			return columnError("ip_from", &IPNumberError{Value: parts[0]})
+		}
+		if !ips.endOK || start.cmp(end) > 0 {
+			return columnError("ip_to", &IPNumberError{Value: parts[1]})
+		}
+
+		if ipFamily == "4" {
+			return addIPv4(start, end, parts)
+		}
+
+		if start.cmp(ipv4MappedStartNumber) < 0 { // IPv6 below the IPv4-mapped range
+			pieceEnd := end
+			if pieceEnd.cmp(ipv4MappedStartNumber) >= 0 {
+				pieceEnd = ipv4MappedStartNumber.subOne()
+			}
+			if err := addIPv6(start, pieceEnd, parts); err != nil {
+				return err
+			}
+		}
+		if start.cmp(ipv4MappedEndNumber) <= 0 && end.cmp(ipv4MappedStartNumber) >= 0 { // IPv4-mapped range
+			pieceStart := start
+			if pieceStart.cmp(ipv4MappedStartNumber) < 0 {
+				pieceStart = ipv4MappedStartNumber
+			}
+			pieceEnd := end
+			if pieceEnd.cmp(ipv4MappedEndNumber) > 0 {
+				pieceEnd = ipv4MappedEndNumber
+			}
+			if err := addIPv4(pieceStart.sub(ipv4MappedStartNumber), pieceEnd.sub(ipv4MappedStartNumber), parts); err != nil {
+				return err
+			}
+		}
+		if end.cmp(ipv4MappedEndNumber) > 0 { // IPv6 after the IPv4-mapped range
+			if !ipv4Done {
+				if err := finishIPv4(); err != nil {
+					return err
+				}
+			}
+			pieceStart := start
+			if pieceStart.cmp(ipv4MappedEndNumber) <= 0 {
+				pieceStart = ipv4MappedEndNumber.addOne()
+			}
+			if err := addIPv6(pieceStart, end, parts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for {
+		parts, err := csvRdr.Read()
+		if err == io.EOF {
+			break
+		} else if err == nil {
+			if err = addRecord(parts); err != nil {
+				line, _ := csvRdr.FieldPos(0)
+				err = lineError(err, line, parts)
+			}
+		}
+		if err != nil {
+			if err = handler.handle(err); err != nil {
+				return err
+			}
+		}
+	}
+
+	if ipFamily == "4" {
+		if !next4.before(maxIPv4Number) {
+			return fn(false, next4.next, maxIPv4Number, empty)
+		}
+		return nil
+	}
+
+	if !ipv4Done {
+		if err = finishIPv4(); err != nil {
+			return err
+		}
+	}
+	if !next6.end {
+		return addIPv6(next6.next, maxIPv6Number, empty)
+	}
+	return nil
+}
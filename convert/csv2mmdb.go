@@ -0,0 +1,601 @@
+package convert
+
+import (
+	"fmt"
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IP2Location net speed to GeoIP2 connection type
+var connectionTypes = map[string]string{
+	"DIAL": "Dialup",
+	"DSL":  "Cable/DSL",
+	"COMP": "Corporate",
+	"T1":   "Corporate",
+	"SAT":  "Satellite",
+}
+
+// IP2Proxy proxy types that hide the real IP address, the data center ranges (DCH) are hosting providers instead
+var anonymousProxyTypes = map[string]bool{
+	"VPN": true,
+	"TOR": true,
+	"PUB": true,
+	"WEB": true,
+	"RES": true,
+	"CPN": true,
+	"EPN": true,
+}
+
+// MMDBOptions are the options for ConvertCSV2MMDB, ConvertBIN2MMDB and ConvertMMDB2CSV
+type MMDBOptions struct {
+	Type    string // "country", "city", "asn", "isp" or "proxy"
+	Package uint8  // DB package to convert with all its fields instead of the MMDB type, csv2mmdb only
+
+	// OnError is called for the bad CSV records instead of stopping at the first one, csv2mmdb only. Use
+	// SkipRecords or QuarantineRecords.
+	OnError RecordErrorFunc
+
+	// Jobs is the number of goroutines parsing the CSV, csv2mmdb only. 1 or less parses it on the calling
+	// goroutine, the MMDB is the same for any number.
+	Jobs int
+
+	// Date is the date of the database for the MMDB build epoch and description. It is required for
+	// ConvertCSV2MMDB, ConvertBIN2MMDB uses the BIN date if zero.
+	Date time.Time
+}
+
+// ConvertCSV2MMDB converts the IP2Location or IP2Proxy CSV into the MMDB for the MMDB type or the DB package.
+func ConvertCSV2MMDB(in io.Reader, out io.Writer, opts MMDBOptions) error {
+	var dbType uint8 = 0 // only used when converting by DB package
+	var pxType uint8 = 0 // only used when converting IP2Proxy CSV, detected from the column count
+	mmdbType := opts.Type
+
+	delim := ','
+
+	var dbDesc string
+
+	if opts.Package != 0 {
+		if opts.Package > 26 {
+			return newClassError(ErrOption, "Invalid DB package.")
+		}
+		dbType = opts.Package
+		dbDesc = DBPackageMMDBType(dbType)
+	} else if mmdbType == "country" {
+		dbDesc = "GeoLite2Country database" // need this to be able to use the Maxmind API for GeoLite2 Country
+	} else if mmdbType == "city" {
+		dbDesc = "GeoLite2City database" // need this to be able to use the Maxmind API for GeoLite2 City
+	} else if mmdbType == "asn" {
+		dbDesc = "GeoLite2-ASN" // Maxmind API checks for the exact type before doing ASN lookups
+	} else if mmdbType == "isp" {
+		dbDesc = "GeoIP2-ISP" // Maxmind API checks for the exact type before doing ISP lookups
+	} else if mmdbType == "proxy" {
+		dbDesc = "IP2Proxy database"
+	} else {
+		return newClassError(ErrOption, "Invalid MMDB type.")
+	}
+	tree, err := NewMMDBTree(dbDesc, opts.Date)
+	if err != nil {
+		return err
+	}
+
+	entryCnt := 0
+	rdr, stopCSV := newCSVReader(in, true, opts.Jobs, opts.OnError != nil) // column count is checked below for the MMDB type
+	defer stopCSV()
+
+	addRecord := func(parts []string) error {
+		if dbType > 0 && len(parts) != int(columnSize[dbType])+2 {
+			return &ColumnCountError{Package: fmt.Sprintf("DB%d", dbType), Expected: []int{int(columnSize[dbType]) + 2}, Found: len(parts)}
+		} else if mmdbType == "country" && len(parts) != 4 {
+			return &ColumnCountError{Package: "DB1", Expected: []int{4}, Found: len(parts)}
+		} else if mmdbType == "city" && len(parts) != 9 {
+			return &ColumnCountError{Package: "DB9", Expected: []int{9}, Found: len(parts)}
+		} else if (mmdbType == "asn" || mmdbType == "isp") && len(parts) != 5 && len(parts) != 27 {
+			return &ColumnCountError{Package: "ASN or DB26", Expected: []int{5, 27}, Found: len(parts)}
+		} else if mmdbType == "proxy" && pxType == 0 {
+			if pxType = PXTypeFromColumns(len(parts)); pxType == 0 {
+				counts := []int{}
+				for _, size := range pxColumnSize[1:] {
+					counts = append(counts, int(size)+2)
+				}
+				return &ColumnCountError{Package: "PX1 to PX12", Expected: counts, Found: len(parts)}
+			}
+		} else if mmdbType == "proxy" && len(parts) != int(pxColumnSize[pxType])+2 {
+			return &ColumnCountError{Package: fmt.Sprintf("PX%d", pxType), Expected: []int{int(pxColumnSize[pxType]) + 2}, Found: len(parts)}
+		}
+
+		var err error
+		if dbType > 0 {
+			err = AppendDBCSVRecord(delim, parts, tree, dbType)
+		} else if mmdbType == "country" {
+			err = AppendDB1CSVRecord(delim, parts, tree)
+		} else if mmdbType == "city" {
+			err = AppendDB9CSVRecord(delim, parts, tree)
+		} else if mmdbType == "asn" || mmdbType == "isp" {
+			err = AppendASNCSVRecord(delim, parts, tree, mmdbType)
+		} else if mmdbType == "proxy" {
+			err = AppendProxyCSVRecord(delim, parts, tree, pxType)
+		}
+		if err != nil {
+			return recordError(err)
+		}
+		return nil
+	}
+
+	handler := &recordErrorHandler{onError: opts.OnError}
+	for {
+		parts, err := rdr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			err = csvReadError(err)
+		} else if err = addRecord(parts); err != nil {
+			line, _ := rdr.FieldPos(0)
+			err = lineError(err, line, parts)
+		} else {
+			entryCnt += 1
+			continue
+		}
+
+		if err = handler.handle(err); err != nil {
+			return err
+		}
+	}
+
+	if entryCnt == 0 {
+		return newClassError(ErrInvalidData, "Nothing to import.")
+	}
+
+	if _, err := tree.WriteTo(out); err != nil {
+		return &IOError{Op: "Writing out to tree failed.", Err: err}
+	}
+	return SyncOutput(out)
+}
+
+// DBPackageMMDBType returns the MMDB database type with the Maxmind API lookups that can read the DB package fields
+func DBPackageMMDBType(dbType uint8) string {
+	if ispPosition[dbType] > 0 || domainPosition[dbType] > 0 || asnPosition[dbType] > 0 {
+		return "GeoIP2Enterprise database" // need this to be able to use the Maxmind API for GeoIP2 Enterprise which has the traits
+	} else if cityPosition[dbType] > 0 {
+		return "GeoLite2City database"
+	}
+	return "GeoLite2Country database"
+}
+
+// NewMMDBTree returns the tree for the MMDB database type, built on the date of the database which is required
+func NewMMDBTree(dbDesc string, date time.Time) (*mmdbwriter.Tree, error) {
+	ipVersion := 6 // default should be 6 which should cover both IPv4 and IPv6
+	date, err := databaseDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	return mmdbwriter.New(
+		mmdbwriter.Options{
+			BuildEpoch:   date.Unix(),
+			DatabaseType: dbDesc,
+			Description: map[string]string{
+				"en": dbDesc + " " + date.Format("2006-01-02"),
+			},
+			DisableIPv4Aliasing:     false,
+			IncludeReservedNetworks: true,
+			Languages:               []string{"en"},
+			IPVersion:               ipVersion,
+		},
+	)
+}
+
+func AppendDB1CSVRecord(delim rune, parts []string, tree *mmdbwriter.Tree) error {
+	record := mmdbtype.Map{}
+
+	country := mmdbtype.Map{
+		"iso_code": mmdbtype.String(parts[2]),
+		"names": mmdbtype.Map{
+			"en": mmdbtype.String(parts[3]),
+		},
+	}
+	record["country"] = country
+
+	return InsertCSVRange(parts, record, tree)
+}
+
+func AppendDB9CSVRecord(delim rune, parts []string, tree *mmdbwriter.Tree) error {
+	var err error
+
+	record := mmdbtype.Map{}
+
+	country := mmdbtype.Map{
+		"iso_code": mmdbtype.String(parts[2]),
+		"names": mmdbtype.Map{
+			"en": mmdbtype.String(parts[3]),
+		},
+	}
+	subdivision := mmdbtype.Map{
+		"names": mmdbtype.Map{
+			"en": mmdbtype.String(parts[4]),
+		},
+	}
+	subdivisions := mmdbtype.Slice{subdivision}
+
+	city := mmdbtype.Map{
+		"names": mmdbtype.Map{
+			"en": mmdbtype.String(parts[5]),
+		},
+	}
+	var lat float64
+	var long float64
+	if lat, err = strconv.ParseFloat(parts[6], 64); err != nil {
+		return &ParseError{Record: parts, Column: "latitude", Err: newClassError(ErrInvalidData, "Invalid latitude %v.", parts[6])}
+	}
+	if long, err = strconv.ParseFloat(parts[7], 64); err != nil {
+		return &ParseError{Record: parts, Column: "longitude", Err: newClassError(ErrInvalidData, "Invalid longitude %v.", parts[7])}
+	}
+	location := mmdbtype.Map{
+		"latitude":  mmdbtype.Float64(lat),
+		"longitude": mmdbtype.Float64(long),
+	}
+	postal := mmdbtype.Map{
+		"code": mmdbtype.String(parts[8]),
+	}
+	record["country"] = country
+	record["city"] = city
+	record["postal"] = postal
+	record["location"] = location
+	record["subdivisions"] = subdivisions
+
+	return InsertCSVRange(parts, record, tree)
+}
+
+// AppendDBCSVRecord maps the fields of any DB package into the GeoIP2 layout where there is an equivalent field,
+// the rest go into the ip2location map. Fields with "-" are left out.
+func AppendDBCSVRecord(delim rune, parts []string, tree *mmdbwriter.Tree, dbType uint8) error {
+	var err error
+
+	field := func(position [27]uint8) string {
+		if position[dbType] == 0 || parts[position[dbType]+1] == "-" {
+			return ""
+		}
+		return parts[position[dbType]+1]
+	}
+
+	record := mmdbtype.Map{}
+	location := mmdbtype.Map{}
+	traits := mmdbtype.Map{}
+	ip2location := mmdbtype.Map{}
+
+	if parts[2] != "-" {
+		record["country"] = mmdbtype.Map{
+			"iso_code": mmdbtype.String(parts[2]),
+			"names": mmdbtype.Map{
+				"en": mmdbtype.String(parts[3]),
+			},
+		}
+	}
+	if v := field(regionPosition); v != "" {
+		record["subdivisions"] = mmdbtype.Slice{
+			mmdbtype.Map{
+				"names": mmdbtype.Map{
+					"en": mmdbtype.String(v),
+				},
+			},
+		}
+	}
+	if v := field(cityPosition); v != "" {
+		record["city"] = mmdbtype.Map{
+			"names": mmdbtype.Map{
+				"en": mmdbtype.String(v),
+			},
+		}
+	}
+	if latitudePosition[dbType] > 0 {
+		var lat float64
+		var long float64
+		if lat, err = strconv.ParseFloat(parts[latitudePosition[dbType]+1], 64); err != nil {
+			return &ParseError{Record: parts, Column: "latitude", Err: newClassError(ErrInvalidData, "Invalid latitude %v.", parts[latitudePosition[dbType]+1])}
+		}
+		if long, err = strconv.ParseFloat(parts[longitudePosition[dbType]+1], 64); err != nil {
+			return &ParseError{Record: parts, Column: "longitude", Err: newClassError(ErrInvalidData, "Invalid longitude %v.", parts[longitudePosition[dbType]+1])}
+		}
+		location["latitude"] = mmdbtype.Float64(lat)
+		location["longitude"] = mmdbtype.Float64(long)
+	}
+	if v := field(zipCodePosition); v != "" {
+		record["postal"] = mmdbtype.Map{
+			"code": mmdbtype.String(v),
+		}
+	}
+	if v := field(timeZonePosition); v != "" {
+		location["time_zone"] = mmdbtype.String(v) // UTC offset instead of the time zone name
+	}
+	if v := field(ispPosition); v != "" {
+		traits["isp"] = mmdbtype.String(v)
+	}
+	if v := field(domainPosition); v != "" {
+		traits["domain"] = mmdbtype.String(v)
+	}
+	if v := field(netSpeedPosition); v != "" {
+		if connectionType, ok := connectionTypes[v]; ok {
+			traits["connection_type"] = mmdbtype.String(connectionType)
+		}
+		ip2location["net_speed"] = mmdbtype.String(v)
+	}
+	if v := field(iddCodePosition); v != "" {
+		ip2location["idd_code"] = mmdbtype.String(v)
+	}
+	if v := field(areaCodePosition); v != "" {
+		ip2location["area_code"] = mmdbtype.String(v)
+	}
+	if v := field(weatherStationCodePosition); v != "" {
+		ip2location["weather_station_code"] = mmdbtype.String(v)
+	}
+	if v := field(weatherStationNamePosition); v != "" {
+		ip2location["weather_station_name"] = mmdbtype.String(v)
+	}
+	if v := field(mccPosition); v != "" {
+		traits["mobile_country_code"] = mmdbtype.String(v)
+	}
+	if v := field(mncPosition); v != "" {
+		traits["mobile_network_code"] = mmdbtype.String(v)
+	}
+	if v := field(mobileBrandPosition); v != "" {
+		ip2location["mobile_brand"] = mmdbtype.String(v)
+	}
+	if v := field(elevationPosition); v != "" {
+		if elevation, err := strconv.ParseInt(v, 10, 32); err == nil {
+			ip2location["elevation"] = mmdbtype.Int32(elevation)
+		}
+	}
+	if v := field(usageTypePosition); v != "" {
+		ip2location["usage_type"] = mmdbtype.String(v)
+	}
+	if v := field(addressTypePosition); v != "" {
+		ip2location["address_type"] = mmdbtype.String(v)
+	}
+	if v := field(categoryPosition); v != "" {
+		ip2location["category"] = mmdbtype.String(v)
+	}
+	if v := field(districtPosition); v != "" {
+		ip2location["district"] = mmdbtype.String(v)
+	}
+	if v := field(asnPosition); v != "" {
+		if asn, err := strconv.ParseUint(v, 10, 32); err == nil {
+			traits["autonomous_system_number"] = mmdbtype.Uint32(asn)
+		}
+	}
+	if v := field(asPosition); v != "" {
+		traits["autonomous_system_organization"] = mmdbtype.String(v)
+	}
+
+	if len(location) > 0 {
+		record["location"] = location
+	}
+	if len(traits) > 0 {
+		record["traits"] = traits
+	}
+	if len(ip2location) > 0 {
+		record["ip2location"] = ip2location
+	}
+
+	return InsertCSVRange(parts, record, tree)
+}
+
+// AppendASNCSVRecord writes the GeoLite2-ASN or GeoIP2-ISP record using either the IP2Location ASN CSV or DB26 CSV.
+// IP2Location does not have the organization so the ISP is used, or the AS name for the ASN CSV.
+func AppendASNCSVRecord(delim rune, parts []string, tree *mmdbwriter.Tree, mmdbType string) error {
+	var asn string
+	var as string
+	var isp string
+	var mcc string
+	var mnc string
+
+	if len(parts) == 5 { // ASN CSV has the CIDR in the 3rd column
+		asn = parts[3]
+		as = parts[4]
+		isp = parts[4]
+	} else {
+		asn = parts[asnPosition[26]+1]
+		as = parts[asPosition[26]+1]
+		isp = parts[ispPosition[26]+1]
+		mcc = parts[mccPosition[26]+1]
+		mnc = parts[mncPosition[26]+1]
+	}
+
+	record := mmdbtype.Map{}
+
+	if asn != "-" {
+		asnNum, err := strconv.ParseUint(asn, 10, 32)
+		if err != nil {
+			return &ParseError{Record: parts, Column: "asn", Err: newClassError(ErrInvalidData, "Invalid ASN %v.", asn)}
+		}
+		record["autonomous_system_number"] = mmdbtype.Uint32(asnNum)
+		record["autonomous_system_organization"] = mmdbtype.String(as)
+	}
+	if mmdbType == "isp" {
+		if isp != "-" {
+			record["isp"] = mmdbtype.String(isp)
+			record["organization"] = mmdbtype.String(isp)
+		}
+		if mcc != "" && mcc != "-" {
+			record["mobile_country_code"] = mmdbtype.String(mcc)
+		}
+		if mnc != "" && mnc != "-" {
+			record["mobile_network_code"] = mmdbtype.String(mnc)
+		}
+	}
+
+	if len(record) == 0 {
+		return nil // unassigned ranges are left out like in the GeoLite2-ASN
+	}
+
+	return InsertCSVRange(parts, record, tree)
+}
+
+// PXTypeFromColumns returns the IP2Proxy package with the CSV column count or 0 if none.
+// PX9 and PX10 have the same columns so PX9 is returned for both.
+func PXTypeFromColumns(count int) uint8 {
+	for pxType := uint8(1); pxType <= 12; pxType++ {
+		if int(pxColumnSize[pxType])+2 == count {
+			return pxType
+		}
+	}
+	return 0
+}
+
+// AppendProxyCSVRecord maps the IP2Proxy fields into the GeoIP2 anonymous and hosting traits, with the proxy
+// specific fields at the top level. Fields with "-" are left out.
+func AppendProxyCSVRecord(delim rune, parts []string, tree *mmdbwriter.Tree, pxType uint8) error {
+	countryIndex := int(pxCountryPosition[pxType])
+
+	if EqualFields(parts[2:], EmptyCSVRecord(pxColumnSize[pxType])[2:]) {
+		return nil // not a proxy, like the ranges filled in by csv2bin
+	}
+
+	field := func(position [13]uint8) string {
+		index := int(position[pxType])
+		if index == 0 {
+			return ""
+		}
+		if index > countryIndex {
+			index++ // country has 2 columns
+		}
+		if parts[index] == "-" {
+			return ""
+		}
+		return parts[index]
+	}
+
+	record := mmdbtype.Map{}
+	traits := mmdbtype.Map{}
+
+	if parts[countryIndex] != "-" {
+		record["country"] = mmdbtype.Map{
+			"iso_code": mmdbtype.String(parts[countryIndex]),
+			"names": mmdbtype.Map{
+				"en": mmdbtype.String(parts[countryIndex+1]),
+			},
+		}
+	}
+	if v := field(pxRegionPosition); v != "" {
+		record["subdivisions"] = mmdbtype.Slice{
+			mmdbtype.Map{
+				"names": mmdbtype.Map{
+					"en": mmdbtype.String(v),
+				},
+			},
+		}
+	}
+	if v := field(pxCityPosition); v != "" {
+		record["city"] = mmdbtype.Map{
+			"names": mmdbtype.Map{
+				"en": mmdbtype.String(v),
+			},
+		}
+	}
+
+	if pxProxyTypePosition[pxType] == 0 {
+		traits["is_anonymous_proxy"] = mmdbtype.Bool(true) // PX1 only lists the proxies without the type
+	} else if v := field(pxProxyTypePosition); v != "" {
+		if anonymousProxyTypes[v] {
+			traits["is_anonymous_proxy"] = mmdbtype.Bool(true)
+		}
+		if v == "DCH" {
+			traits["is_hosting_provider"] = mmdbtype.Bool(true)
+		}
+		record["proxy_type"] = mmdbtype.String(v)
+	}
+	if v := field(pxISPPosition); v != "" {
+		traits["isp"] = mmdbtype.String(v)
+	}
+	if v := field(pxDomainPosition); v != "" {
+		traits["domain"] = mmdbtype.String(v)
+	}
+	if v := field(pxUsageTypePosition); v != "" {
+		record["usage_type"] = mmdbtype.String(v)
+	}
+	if v := field(pxASNPosition); v != "" {
+		if asn, err := strconv.ParseUint(v, 10, 32); err == nil {
+			traits["autonomous_system_number"] = mmdbtype.Uint32(asn)
+		}
+	}
+	if v := field(pxASPosition); v != "" {
+		traits["autonomous_system_organization"] = mmdbtype.String(v)
+	}
+	if v := field(pxLastSeenPosition); v != "" {
+		if lastSeen, err := strconv.ParseUint(v, 10, 32); err == nil {
+			record["last_seen"] = mmdbtype.Uint32(lastSeen) // number of days
+		}
+	}
+	if v := field(pxThreatPosition); v != "" {
+		record["threat"] = mmdbtype.String(v)
+	}
+	if v := field(pxProviderPosition); v != "" {
+		record["provider"] = mmdbtype.String(v)
+	}
+	if v := field(pxFraudScorePosition); v != "" {
+		if fraudScore, err := strconv.ParseUint(v, 10, 16); err == nil {
+			record["fraud_score"] = mmdbtype.Uint16(fraudScore)
+		}
+	}
+
+	if len(traits) > 0 {
+		record["traits"] = traits
+	}
+
+	return InsertCSVRange(parts, record, tree)
+}
+
+// InsertCSVRange inserts the record for the range in the first 2 CSV columns
+func InsertCSVRange(parts []string, record mmdbtype.Map, tree *mmdbwriter.Tree) error {
+	var err error
+
+	// these 2 fields are used for the special case where we need to split a range due the Go handling of IPv4-mapped IPv6 being treated as plain IPv4
+	oriStartNum := parts[0]
+	oriEndNum := parts[1]
+
+	skipSpecialCase := false
+
+	if oriEndNum == "281474976710655" {
+		// should be LITE CSV which does not have range with IPv4 start and IPv6 end
+		skipSpecialCase = true
+	}
+
+	startNum, ok := parseUint128(parts[0])
+	if !ok {
+		return &ParseError{Record: parts, Column: "ip_from", Err: &IPNumberError{Value: parts[0]}}
+	}
+
+	endNum, ok := parseUint128(parts[1])
+	if !ok {
+		return &ParseError{Record: parts, Column: "ip_to", Err: &IPNumberError{Value: parts[1]}}
+	}
+
+	if err = tree.InsertRange(startNum.ip(), endNum.ip(), record); err != nil {
+		if skipSpecialCase {
+			if strings.Contains(err.Error(), "start & end IPs did not give valid range") { // special case where start IP is IPv4-mapped IPv6 (converted by Go into plain IPv4)
+				// need to split into 2 ranges
+				splitIPv4 := make([]string, len(parts))
+				splitIPv6 := make([]string, len(parts))
+				copy(splitIPv4, parts)
+				copy(splitIPv6, parts)
+
+				splitIPv4[0] = oriStartNum
+				splitIPv4[1] = "281474976710655"
+				splitIPv6[0] = "281474976710656"
+				splitIPv6[1] = oriEndNum
+
+				if err = InsertCSVRange(splitIPv4, record, tree); err != nil {
+					return err
+				}
+				if err = InsertCSVRange(splitIPv6, record, tree); err != nil {
+					return err
+				}
+			} else if !strings.Contains(err.Error(), "which is in an aliased network") {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"sync"
+)
+
+// csvChunkSize is the size of the chunks the CSV is split into for the parsing goroutines, the chunks are a bit
+// bigger to end at the end of a record. The tests make it smaller to split their small CSV files.
+var csvChunkSize = 256 * 1024
+
+// newCSVReader returns the reader for the CSV to convert. With jobs above 1 the CSV is parsed by that many
+// goroutines, otherwise by a csv.Reader on the calling goroutine, the records and the errors are the same either
+// way. With badLines the CSV is always split into chunks so that the CSV syntax errors come with the lines of the
+// bad record, for the OnError option. stop ends the goroutines if the CSV is not read to the end, the reader
+// cannot be used after.
+func newCSVReader(in io.Reader, lazyQuotes bool, jobs int, badLines bool) (rdr csvRecordReader, stop func()) {
+	if jobs <= 1 && !badLines {
+		return newStdCSVReader(bufio.NewReaderSize(in, 65536), lazyQuotes), func() {}
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	r := &parallelCSVReader{
+		lazyQuotes: lazyQuotes,
+		work:       make(chan *csvChunk),
+		order:      make(chan *csvChunk, 2*jobs),
+		done:       make(chan struct{}),
+	}
+	go r.split(in)
+	for i := 0; i < jobs; i++ {
+		go r.parse()
+	}
+	return r, r.stop
+}
+
+// newStdCSVReader returns the csv.Reader for the converters, the column count is checked by each converter
+func newStdCSVReader(in io.Reader, lazyQuotes bool) *csv.Reader {
+	csvRdr := csv.NewReader(in)
+	csvRdr.Comma = ','
+	csvRdr.LazyQuotes = lazyQuotes
+	csvRdr.FieldsPerRecord = -1
+	return csvRdr
+}
+
+// parallelCSVReader parses the CSV on several goroutines. A goroutine splits the input into chunks of whole
+// records, the workers parse the chunks with csv.Reader and Read returns the records in the order of the chunks.
+type parallelCSVReader struct {
+	lazyQuotes bool
+	work       chan *csvChunk // chunks for the workers
+	order      chan *csvChunk // the same chunks in CSV order, its size limits the chunks in memory
+	done       chan struct{}  // closed by stop
+	stopOnce   sync.Once
+
+	chunk  *csvChunk // chunk being read
+	pos    int       // next record in the chunk
+	record *csvChunkRecord
+}
+
+// csvChunk is a part of the CSV that ends at the end of a record
+type csvChunk struct {
+	data    []byte
+	line    int   // lines before the chunk
+	err     error // read error after the chunk
+	records []csvChunkRecord
+	parsed  chan struct{} // closed when the records are parsed
+}
+
+// csvChunkRecord is a record or the error in its place
+type csvChunkRecord struct {
+	parts []string
+	line  int
+	ips   csvIPRange
+	err   error
+}
+
+func (r *parallelCSVReader) stop() {
+	r.stopOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// split reads the input into chunks for the workers and for Read
+func (r *parallelCSVReader) split(in io.Reader) {
+	defer close(r.work)
+	defer close(r.order)
+
+	buf := make([]byte, 0, 2*csvChunkSize)
+	scanned := 0 // bytes of buf checked for the record ends
+	end := 0     // end of the last whole record in buf
+	lines, endLines := 0, 0
+	quoted := false // the last line ends inside a quoted field
+	line := 0
+	for {
+		if len(buf) == cap(buf) { // a record longer than the buffer
+			buf = append(buf, make([]byte, cap(buf))...)[:len(buf)]
+		}
+		n, err := in.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+
+		for {
+			i := bytes.IndexByte(buf[scanned:], '\n')
+			if i < 0 {
+				break
+			}
+			quoted = !csvLineEndsRecord(buf[scanned:scanned+i], quoted, r.lazyQuotes)
+			scanned += i + 1
+			lines++
+			if !quoted {
+				end, endLines = scanned, lines
+			}
+		}
+
+		if err == io.EOF { // the rest is the last chunk
+			r.send(&csvChunk{data: buf, line: line})
+			return
+		} else if err != nil { // the records cut off by the error are left out like csv.Reader does
+			r.send(&csvChunk{data: buf[:end], line: line, err: err})
+			return
+		}
+		if end >= csvChunkSize {
+			rest := append(make([]byte, 0, 2*csvChunkSize), buf[end:]...)
+			if !r.send(&csvChunk{data: buf[:end], line: line}) {
+				return
+			}
+			buf = rest
+			scanned -= end
+			line += endLines
+			lines -= endLines
+			end, endLines = 0, 0
+		}
+	}
+}
+
+// send queues the chunk for Read and for the workers, false if stopped
+func (r *parallelCSVReader) send(c *csvChunk) bool {
+	c.parsed = make(chan struct{})
+	select {
+	case r.order <- c:
+	case <-r.done:
+		return false
+	}
+	select {
+	case r.work <- c:
+	case <-r.done:
+		return false
+	}
+	return true
+}
+
+// parse parses the chunks until the input is split, the line numbers are counted from the start of the CSV
+func (r *parallelCSVReader) parse() {
+	for c := range r.work {
+		csvRdr := newStdCSVReader(bytes.NewReader(c.data), r.lazyQuotes)
+		for {
+			parts, err := csvRdr.Read()
+			if err == io.EOF {
+				break
+			}
+			rec := csvChunkRecord{parts: parts, err: err}
+			var parseErr *csv.ParseError
+			if err == nil {
+				rec.line, _ = csvRdr.FieldPos(0)
+				rec.line += c.line
+				rec.ips = parseCSVIPRange(parts)
+			} else if errors.As(err, &parseErr) {
+				moved := *parseErr
+				moved.StartLine += c.line
+				moved.Line += c.line
+				rec.err = &csvSyntaxError{err: &moved, lines: chunkLines(c.data, parseErr.StartLine, parseErr.Line)}
+			}
+			c.records = append(c.records, rec)
+		}
+		if c.err != nil {
+			c.records = append(c.records, csvChunkRecord{err: c.err})
+		}
+		c.data = nil
+		close(c.parsed)
+	}
+}
+
+// Read returns the next record like csv.Reader Read
+func (r *parallelCSVReader) Read() ([]string, error) {
+	for r.chunk == nil || r.pos == len(r.chunk.records) {
+		c, ok := <-r.order
+		if !ok {
+			return nil, io.EOF
+		}
+		<-c.parsed
+		r.chunk, r.pos = c, 0
+	}
+	r.record = &r.chunk.records[r.pos]
+	r.pos++
+	return r.record.parts, r.record.err
+}
+
+// FieldPos returns the CSV line of the last record read
+func (r *parallelCSVReader) FieldPos(field int) (int, int) {
+	return r.record.line, 0
+}
+
+// csvSyntaxError is a csv.Reader syntax error with the lines of the bad record
+type csvSyntaxError struct {
+	err   *csv.ParseError
+	lines string
+}
+
+func (e *csvSyntaxError) Error() string {
+	return e.err.Error()
+}
+
+func (e *csvSyntaxError) Unwrap() error {
+	return e.err
+}
+
+// chunkLines returns the lines first to last of the chunk, without the last line break
+func chunkLines(data []byte, first int, last int) string {
+	start := 0
+	for line := 1; line < first && start < len(data); line++ {
+		i := bytes.IndexByte(data[start:], '\n')
+		if i < 0 {
+			return ""
+		}
+		start += i + 1
+	}
+	end := start
+	for line := first; line <= last && end < len(data); line++ {
+		i := bytes.IndexByte(data[end:], '\n')
+		if i < 0 {
+			end = len(data)
+			break
+		}
+		end += i + 1
+	}
+	return string(bytes.TrimRight(data[start:end], "\r\n"))
+}
+
+// recordIPRange returns the IP range of the record last read by rdr, the parallel reader already parsed it
+func recordIPRange(rdr csvRecordReader, parts []string) csvIPRange {
+	if p, ok := rdr.(*parallelCSVReader); ok {
+		return p.record.ips
+	}
+	return parseCSVIPRange(parts)
+}
+
+// csvLineEndsRecord returns true if the CSV record ends with the line, without the line break. quoted is true if
+// the line starts inside a quoted field of the lines before. The record ends where csv.Reader ends it, including
+// at the bare quotes that it stops at with an error.
+func csvLineEndsRecord(line []byte, quoted bool, lazyQuotes bool) bool {
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1] // csv.Reader reads \r\n as \n
+	}
+	for {
+		if !quoted {
+			if len(line) == 0 || line[0] != '"' {
+				i := bytes.IndexByte(line, ',')
+				field := line
+				if i >= 0 {
+					field = line[:i]
+				}
+				if (!lazyQuotes && bytes.IndexByte(field, '"') >= 0) || i < 0 {
+					return true
+				}
+				line = line[i+1:]
+				continue
+			}
+			line = line[1:]
+			quoted = true
+		}
+
+		i := bytes.IndexByte(line, '"')
+		if i < 0 {
+			return false // the quoted field goes on in the next line
+		}
+		line = line[i+1:]
+		switch {
+		case len(line) == 0:
+			return true
+		case line[0] == '"':
+			line = line[1:]
+		case line[0] == ',':
+			line = line[1:]
+			quoted = false
+		case !lazyQuotes:
+			return true
+		}
+	}
+}
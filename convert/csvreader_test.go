@@ -0,0 +1,30 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// TestJobs checks that parsing the CSV on several goroutines gives the same output as parsing it on one
+func TestJobs(t *testing.T) {
+	defer func(size int) { csvChunkSize = size }(csvChunkSize)
+	csvChunkSize = 200 // a few records in each chunk
+
+	for _, c := range testConversions {
+		in := readTestdata(t, c.file)
+		var want bytes.Buffer
+		if err := c.convert(bytes.NewReader(in), &want, 1); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for _, jobs := range []int{2, 3, 8} {
+			t.Run(fmt.Sprintf("%s/%d", c.name, jobs), func(t *testing.T) {
+				var got bytes.Buffer
+				if err := c.convert(bytes.NewReader(in), &got, jobs); err != nil {
+					t.Fatal(err)
+				}
+				checkBytes(t, "output", got.Bytes(), want.Bytes())
+			})
+		}
+	}
+}
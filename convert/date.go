@@ -0,0 +1,56 @@
+package convert
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dates in the file names, like 2024-01-31, 2024_01_31 or 20240131
+var nameDateRegex = regexp.MustCompile(`(^|[^0-9])(20[0-9]{2})([-_.]?)([0-9]{2})([-_.]?)([0-9]{2})([^0-9]|$)`)
+
+// DateFromName returns the date in the file name, like 2024-01-31 or 20240131, ok is false if it has none
+func DateFromName(name string) (date time.Time, ok bool) {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	for _, m := range nameDateRegex.FindAllStringSubmatch(name, -1) {
+		if m[3] != m[5] {
+			continue
+		}
+		if date, err := time.Parse("2006-01-02", m[2]+"-"+m[4]+"-"+m[6]); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// databaseDate returns the day of the date at midnight UTC. The date is required so the same CSV always gives the
+// same database, the CLI defaults it from the file.
+func databaseDate(date time.Time) (time.Time, error) {
+	if date.IsZero() {
+		return time.Time{}, newClassError(ErrOption, "Date not specified.")
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// binDate returns the date for the BIN header, which only has room for the years 2000 to 2099
+func binDate(date time.Time) (year uint8, month uint8, day uint8, err error) {
+	date, err = databaseDate(date)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if date.Year() < 2000 || date.Year() > 2099 {
+		return 0, 0, 0, newClassError(ErrOption, "Invalid date, the BIN date must be from 2000 to 2099.")
+	}
+	return uint8(date.Year() % 100), uint8(date.Month()), uint8(date.Day()), nil
+}
+
+// headerDate returns the date in the BIN header. time.Date would turn a bad date like 0/0/0 into 1999-11-30 so the
+// date must come back with the same day and month.
+func headerDate(year uint8, month uint8, day uint8) (time.Time, error) {
+	date := time.Date(2000+int(year), time.Month(month), int(day), 0, 0, 0, 0, time.UTC)
+	if year > 99 || date.Month() != time.Month(month) || date.Day() != int(day) {
+		return time.Time{}, newClassError(ErrInvalidData, "Invalid BIN date 20%02d-%02d-%02d.", year, month, day)
+	}
+	return databaseDate(date)
+}
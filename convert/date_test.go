@@ -0,0 +1,35 @@
+package convert
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHeaderDate(t *testing.T) {
+	tests := []struct {
+		year, month, day uint8
+		want             string // empty when the date is invalid
+	}{
+		{21, 1, 20, "2021-01-20"},
+		{0, 1, 1, "2000-01-01"},
+		{99, 12, 31, "2099-12-31"},
+		{24, 2, 29, "2024-02-29"},
+		{0, 0, 0, ""}, // 1999-11-30 after time.Date
+		{23, 2, 29, ""},
+		{21, 13, 1, ""},
+		{21, 4, 31, ""},
+		{100, 1, 1, ""},
+	}
+	for _, tt := range tests {
+		date, err := headerDate(tt.year, tt.month, tt.day)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidData) {
+				t.Errorf("headerDate(%d, %d, %d) = %v, %v, want ErrInvalidData", tt.year, tt.month, tt.day, date, err)
+			}
+			continue
+		}
+		if err != nil || date.Format("2006-01-02") != tt.want {
+			t.Errorf("headerDate(%d, %d, %d) = %v, %v, want %v", tt.year, tt.month, tt.day, date, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package convert
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// magic bytes at the start of the compressed files
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic   = []byte("PK\x03\x04")
+)
+
+// DecompressCSV returns the CSV in the input, decompressed if the input is gzip, bzip2, xz or zstd compressed or a
+// ZIP archive, which is detected from the magic bytes. entry is the CSV file to read from the ZIP archive, the only
+// .CSV file in it if blank. If the input is an io.ReadSeeker that can seek, the result is one as well and seeking
+// it to the start decompresses the input again, for VerifyBIN and VerifyMMDB to read the CSV twice. Otherwise the input is
+// only read once, except that a ZIP archive is copied to a temp file first since its entries are listed at its
+// end. Close does not close the input.
+func DecompressCSV(in io.Reader, entry string) (io.ReadCloser, error) {
+	if seeker, ok := in.(io.ReadSeeker); ok {
+		if _, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			return decompressSeeker(seeker, entry)
+		}
+	}
+
+	bufIn := bufio.NewReaderSize(in, 65536)
+	magic, err := bufIn.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, &IOError{Op: "Unable to read input file.", Err: err}
+	}
+	if bytes.HasPrefix(magic, zipMagic) {
+		file, remove, err := spoolInput(bufIn)
+		if err != nil {
+			return nil, err
+		}
+		r, err := decompressSeeker(file, entry)
+		if err != nil {
+			remove()
+			return nil, err
+		}
+		return &spooledReader{ReadSeekCloser: r, remove: remove}, nil
+	}
+
+	open := decompressor(magic)
+	if open == nil {
+		if entry != "" {
+			return nil, errors.New("Input file is not a ZIP archive.")
+		}
+		return io.NopCloser(bufIn), nil
+	}
+	r := &decompressReader{open: func() (io.Reader, error) {
+		return open(bufIn)
+	}}
+	if err = r.reset(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// decompressSeeker is DecompressCSV for the input that can seek
+func decompressSeeker(in io.ReadSeeker, entry string) (io.ReadSeekCloser, error) {
+	magic := make([]byte, 6)
+	n, err := io.ReadFull(in, magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, &IOError{Op: "Unable to read input file.", Err: err}
+	}
+	magic = magic[:n]
+	if _, err = in.Seek(0, io.SeekStart); err != nil {
+		return nil, &IOError{Op: "Unable to read input file.", Err: err}
+	}
+
+	var open func() (io.Reader, error)
+	var zipEntry *zip.FileHeader
+	if bytes.HasPrefix(magic, zipMagic) {
+		file, err := zipCSVEntry(in, entry)
+		if err != nil {
+			return nil, err
+		}
+		open = func() (io.Reader, error) {
+			return file.Open()
+		}
+		zipEntry = &file.FileHeader
+	} else if streamOpen := decompressor(magic); streamOpen != nil {
+		open = func() (io.Reader, error) {
+			return streamOpen(bufio.NewReaderSize(in, 65536))
+		}
+	} else if entry != "" {
+		return nil, errors.New("Input file is not a ZIP archive.")
+	} else {
+		return nopSeekCloser{in}, nil
+	}
+
+	r := &decompressReader{in: in, open: open, entry: zipEntry}
+	if err = r.reset(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ZIPEntry returns the header of the ZIP entry that the CSV from DecompressCSV is read from, nil if the input is
+// not a ZIP archive
+func ZIPEntry(csv io.Reader) *zip.FileHeader {
+	if s, ok := csv.(*spooledReader); ok {
+		csv = s.ReadSeekCloser
+	}
+	if d, ok := csv.(*decompressReader); ok {
+		return d.entry
+	}
+	return nil
+}
+
+// decompressor returns the function to decompress the stream for the magic bytes, nil if not compressed
+func decompressor(magic []byte) func(r io.Reader) (io.Reader, error) {
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		}
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		}
+	case bytes.HasPrefix(magic, xzMagic):
+		return func(r io.Reader) (io.Reader, error) {
+			return xz.NewReader(r)
+		}
+	case bytes.HasPrefix(magic, zstdMagic):
+		return func(r io.Reader) (io.Reader, error) {
+			return zstd.NewReader(r)
+		}
+	}
+	return nil
+}
+
+// zipCSVEntry returns the entry from the ZIP archive, or else the only .CSV file in it
+func zipCSVEntry(in io.ReadSeeker, entry string) (*zip.File, error) {
+	at, ok := in.(io.ReaderAt)
+	if !ok {
+		return nil, errors.New("ZIP archive must be read from a file.")
+	}
+	size, err := in.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, &IOError{Op: "Unable to read input file.", Err: err}
+	}
+	zipRdr, err := zip.NewReader(at, size)
+	if err != nil {
+		return nil, &IOError{Op: "Unable to read ZIP archive.", Err: err}
+	}
+
+	var found []*zip.File
+	var names []string
+	for _, file := range zipRdr.File {
+		if entry != "" {
+			if file.Name == entry || path.Base(file.Name) == entry {
+				return file, nil
+			}
+		} else if strings.EqualFold(path.Ext(file.Name), ".csv") && !strings.HasPrefix(file.Name, "__MACOSX/") {
+			found = append(found, file)
+			names = append(names, file.Name)
+		}
+	}
+	if entry != "" {
+		return nil, fmt.Errorf("Entry %v not found in the ZIP archive.", entry)
+	} else if len(found) == 0 {
+		return nil, errors.New("No CSV file found in the ZIP archive.")
+	} else if len(found) > 1 {
+		return nil, fmt.Errorf("More than one CSV file found in the ZIP archive, please specify the entry: %v.", strings.Join(names, ", "))
+	}
+	return found[0], nil
+}
+
+// decompressReader decompresses the input from the start, again after seeking to the start if the input can seek
+type decompressReader struct {
+	in    io.ReadSeeker // nil if the input cannot seek
+	open  func() (io.Reader, error)
+	entry *zip.FileHeader // ZIP entry being read, nil if not a ZIP archive
+	r     io.Reader
+	pos   int64
+}
+
+// reset starts decompressing the input from the start
+func (d *decompressReader) reset() error {
+	if d.r != nil && d.in == nil {
+		return errors.New("Input can only be read once.")
+	}
+	d.Close()
+	if d.in != nil {
+		if _, err := d.in.Seek(0, io.SeekStart); err != nil {
+			return &IOError{Op: "Unable to read input file.", Err: err}
+		}
+	}
+	r, err := d.open()
+	if err != nil {
+		return &IOError{Op: "Unable to decompress input file.", Err: err}
+	}
+	d.r = r
+	d.pos = 0
+	return nil
+}
+
+func (d *decompressReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.pos += int64(n)
+	return n, err
+}
+
+// Seek only seeks to the start or returns the current position, seeking fails if the input cannot seek
+func (d *decompressReader) Seek(offset int64, whence int) (int64, error) {
+	if d.in == nil {
+		return d.pos, errors.New("Input can only be read once.")
+	}
+	switch {
+	case offset == 0 && whence == io.SeekStart:
+		return 0, d.reset()
+	case offset == 0 && whence == io.SeekCurrent:
+		return d.pos, nil
+	}
+	return d.pos, errors.New("Compressed input can only be read from the start.")
+}
+
+// Close releases the decompressor, the zstd decoder has its own goroutines
+func (d *decompressReader) Close() error {
+	switch r := d.r.(type) {
+	case *zstd.Decoder:
+		r.Close()
+	case io.Closer:
+		r.Close()
+	}
+	d.r = nil
+	return nil
+}
+
+// nopSeekCloser is the input that is not compressed, the input is closed by the caller
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+// spooledReader is the CSV from the temp file copy of the input
+type spooledReader struct {
+	io.ReadSeekCloser
+	remove func()
+}
+
+// Close deletes the temp file
+func (s *spooledReader) Close() error {
+	s.ReadSeekCloser.Close()
+	s.remove()
+	return nil
+}
+
+// spoolInput copies the input to a temp file for the readers that need to seek, remove closes and deletes it
+func spoolInput(in io.Reader) (file *os.File, remove func(), err error) {
+	if file, err = os.CreateTemp("", "ip2convert-*.tmp"); err != nil {
+		return nil, nil, &IOError{Op: "Unable to create temp file.", Err: err}
+	}
+	remove = func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	if _, err = io.Copy(file, in); err != nil {
+		remove()
+		return nil, nil, &IOError{Op: "Unable to write temp file.", Err: err}
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		remove()
+		return nil, nil, &IOError{Op: "Unable to read temp file.", Err: err}
+	}
+	return file, remove, nil
+}
@@ -0,0 +1,211 @@
+package convert
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// testCompressions are the compressed inputs for the tests of DecompressCSV, each returns db5.csv compressed. The
+// bzip2 file is checked in since Go can only decompress bzip2.
+var testCompressions = []struct {
+	name     string
+	entry    string // ZIP entry to read
+	compress func(t *testing.T, data []byte) []byte
+}{
+	{"plain", "", func(t *testing.T, data []byte) []byte {
+		return data
+	}},
+	{"gzip", "", func(t *testing.T, data []byte) []byte {
+		return compressTest(t, data, func(out io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(out), nil
+		})
+	}},
+	{"bzip2", "", func(t *testing.T, data []byte) []byte {
+		return readTestdata(t, "db5.csv.bz2")
+	}},
+	{"xz", "", func(t *testing.T, data []byte) []byte {
+		return compressTest(t, data, func(out io.Writer) (io.WriteCloser, error) {
+			return xz.NewWriter(out)
+		})
+	}},
+	{"zstd", "", func(t *testing.T, data []byte) []byte {
+		return compressTest(t, data, func(out io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(out)
+		})
+	}},
+	{"zip", "", func(t *testing.T, data []byte) []byte {
+		return zipTest(t, map[string][]byte{"README_LITE.TXT": []byte("readme"), "IP2LOCATION-LITE-DB5.IPV6.CSV": data})
+	}},
+	{"zip entry", "IP2LOCATION-LITE-DB5.IPV6.CSV", func(t *testing.T, data []byte) []byte {
+		return zipTest(t, map[string][]byte{"IP2LOCATION-LITE-DB5.CSV": []byte("0,0"), "db5/IP2LOCATION-LITE-DB5.IPV6.CSV": data})
+	}},
+}
+
+// compressTest returns the data compressed by the writer from newWriter
+func compressTest(t *testing.T, data []byte, newWriter func(out io.Writer) (io.WriteCloser, error)) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := newWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// zipTest returns the ZIP archive with the files in name order
+func zipTest(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w := zip.NewWriter(&out)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// TestDecompressCSV checks that the compressed inputs give the same CSV as the plain file, also after seeking to
+// the start like VerifyBIN does
+func TestDecompressCSV(t *testing.T) {
+	want := readTestdata(t, "db5.csv")
+	for _, c := range testCompressions {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := DecompressCSV(bytes.NewReader(c.compress(t, want)), c.entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			for i := 0; i < 2; i++ {
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkBytes(t, "CSV", got, want)
+				if _, err = r.(io.Seeker).Seek(0, io.SeekStart); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestNonSeekable checks that converting from and to pipes, like -i - and -o -, gives the same output as converting
+// from and to files. The compressed inputs are all db5.csv.
+func TestNonSeekable(t *testing.T) {
+	for _, c := range testConversions {
+		data := readTestdata(t, c.file)
+		for _, z := range testCompressions {
+			if z.name != "plain" && c.file != "db5.csv" {
+				continue
+			}
+			t.Run(c.name+"/"+z.name, func(t *testing.T) {
+				in := z.compress(t, data)
+				want := convertFiles(t, in, z.entry, c.convert)
+				got := convertPipes(t, in, z.entry, c.convert)
+				checkBytes(t, "output", got, want)
+			})
+		}
+	}
+}
+
+// convertFiles runs the conversion from a file with the input to a file and returns the output
+func convertFiles(t *testing.T, in []byte, entry string, convert func(in io.Reader, out io.Writer, jobs int) error) []byte {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "in"), in, 0666); err != nil {
+		t.Fatal(err)
+	}
+	inFile, err := os.Open(filepath.Join(dir, "in"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inFile.Close()
+	outFile, err := os.Create(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outFile.Close()
+
+	csvIn, err := DecompressCSV(inFile, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer csvIn.Close()
+	if err = convert(csvIn, outFile, 1); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// convertPipes runs the conversion from a pipe with the input to a pipe and returns the output
+func convertPipes(t *testing.T, in []byte, entry string, convert func(in io.Reader, out io.Writer, jobs int) error) []byte {
+	t.Helper()
+	inRdr, inWtr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inRdr.Close()
+	outRdr, outWtr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outRdr.Close()
+
+	go func() {
+		inWtr.Write(in)
+		inWtr.Close()
+	}()
+	var out bytes.Buffer
+	done := make(chan error)
+	go func() {
+		_, err := io.Copy(&out, outRdr)
+		done <- err
+	}()
+
+	csvIn, err := DecompressCSV(inRdr, entry)
+	if err != nil {
+		outWtr.Close()
+		t.Fatal(err)
+	}
+	defer csvIn.Close()
+	err = convert(csvIn, outWtr, 1)
+	outWtr.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,384 @@
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"io"
+	"math/big"
+	"net"
+	"sort"
+)
+
+// diffRangeFunc receives a range in the IP2Location IPv6 CSV numbering with the fields by name
+type diffRangeFunc func(start uint128, end uint128, fields map[string]string) error
+
+type diffRange struct {
+	start  uint128
+	end    uint128
+	fields map[string]string // nil for the IP ranges not in the file
+	done   bool              // set on the ranges sent by the walk
+	err    error
+}
+
+type diffChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type diffChangedRange struct {
+	Type    string       `json:"type"`
+	IPFrom  string       `json:"ip_from"`
+	IPTo    string       `json:"ip_to"`
+	Changes []diffChange `json:"changes"`
+	start   uint128
+	end     uint128
+}
+
+type diffSummary struct {
+	Type      string   `json:"type"`
+	Field     string   `json:"field"`
+	Ranges    int      `json:"ranges"`
+	IPv4Count *big.Int `json:"ipv4_count"`
+	IPv6Count *big.Int `json:"ipv6_count"`
+}
+
+var errDiffStopped = errors.New("Diff stopped.")
+
+// DiffSource is the BIN or MMDB to compare with Diff
+type DiffSource struct {
+	Format string   // "BIN" or "MMDB"
+	names  []string // BIN column names, the MMDB fields are sorted by name instead
+	walk   func(fn diffRangeFunc) error
+}
+
+// Diff writes the IP ranges where the fields are different between the old and new BIN or MMDB to out,
+// followed by the number of ranges and IP addresses changed for each field
+func Diff(oldSrc DiffSource, newSrc DiffSource, format string, out io.Writer) error {
+	if format != "text" && format != "json" {
+		return newClassError(ErrOption, "Invalid output format.")
+	}
+	if oldSrc.Format != newSrc.Format {
+		return errors.New("Both files should be BIN or both should be MMDB.")
+	}
+
+	// BIN fields are kept in the column order, MMDB fields are sorted by name
+	fieldRank := map[string]int{}
+	for _, name := range concatSlice(oldSrc.names, newSrc.names) {
+		if _, ok := fieldRank[name]; !ok {
+			fieldRank[name] = len(fieldRank)
+		}
+	}
+	sortFields := func(names []string) {
+		sort.Slice(names, func(i, j int) bool {
+			ri, oki := fieldRank[names[i]]
+			rj, okj := fieldRank[names[j]]
+			if oki != okj {
+				return oki
+			}
+			if ri != rj {
+				return ri < rj
+			}
+			return names[i] < names[j]
+		})
+	}
+
+	summaries := map[string]*diffSummary{}
+	var pending *diffChangedRange
+	flush := func() {
+		if pending == nil {
+			return
+		}
+		pending.IPFrom = DiffRangeIP(pending.start)
+		pending.IPTo = DiffRangeIP(pending.end)
+		if format == "json" {
+			line, _ := json.Marshal(pending)
+			fmt.Fprintln(out, string(line))
+		} else {
+			fmt.Fprintf(out, "%s - %s\n", pending.IPFrom, pending.IPTo)
+			for _, c := range pending.Changes {
+				fmt.Fprintf(out, "  %s: %q -> %q\n", c.Field, c.Old, c.New)
+			}
+		}
+
+		count := pending.end.sub(pending.start).bigInt()
+		count.Add(count, big.NewInt(1)) // the whole IPv6 range does not fit in 128 bits
+		for _, c := range pending.Changes {
+			s, ok := summaries[c.Field]
+			if !ok {
+				s = &diffSummary{Type: "summary", Field: c.Field, IPv4Count: big.NewInt(0), IPv6Count: big.NewInt(0)}
+				summaries[c.Field] = s
+			}
+			s.Ranges++
+			if pending.start.ipv4Mapped() && pending.end.ipv4Mapped() {
+				s.IPv4Count.Add(s.IPv4Count, count)
+			} else {
+				s.IPv6Count.Add(s.IPv6Count, count)
+			}
+		}
+		pending = nil
+	}
+
+	done := make(chan struct{})
+	defer close(done) // stops the walks if the diff ends early
+	oldRanges := StartDiffWalk(oldSrc.walk, done)
+	newRanges := StartDiffWalk(newSrc.walk, done)
+
+	start := uint128{}
+	a := <-oldRanges
+	b := <-newRanges
+	for {
+		if a.err != nil {
+			return a.err
+		}
+		if b.err != nil {
+			return b.err
+		}
+		if !a.done || !b.done { // walk ended before the max IP number
+			return &IOError{Op: "Unable to read input file.", Err: io.ErrUnexpectedEOF}
+		}
+
+		// ranges crossing the IPv4-mapped boundaries are split so the IPv4 and IPv6 counts stay separate
+		end := a.end
+		if b.end.cmp(end) < 0 {
+			end = b.end
+		}
+		if start.cmp(ipv4MappedStartNumber) < 0 && end.cmp(ipv4MappedStartNumber) >= 0 {
+			end = ipv4MappedStartNumber.subOne()
+		} else if start.cmp(ipv4MappedEndNumber) <= 0 && end.cmp(ipv4MappedEndNumber) > 0 {
+			end = ipv4MappedEndNumber
+		}
+
+		if changes := DiffFields(a.fields, b.fields, sortFields); len(changes) > 0 {
+			ipv4 := start.ipv4Mapped()
+			pendingIPv4 := pending != nil && pending.start.ipv4Mapped()
+			if pending != nil && ipv4 == pendingIPv4 && pending.end.addOne() == start && EqualChanges(pending.Changes, changes) {
+				pending.end = end
+			} else {
+				flush()
+				pending = &diffChangedRange{Type: "change", Changes: changes, start: start, end: end}
+			}
+		}
+
+		if end == maxIPv6Number {
+			break
+		}
+		start = end.addOne()
+		if a.end.cmp(end) <= 0 {
+			a = <-oldRanges
+		}
+		if b.end.cmp(end) <= 0 {
+			b = <-newRanges
+		}
+	}
+	flush()
+
+	fields := []string{}
+	for field := range summaries {
+		fields = append(fields, field)
+	}
+	sortFields(fields)
+
+	if format == "json" {
+		for _, field := range fields {
+			line, _ := json.Marshal(summaries[field])
+			fmt.Fprintln(out, string(line))
+		}
+		return nil
+	}
+
+	if len(fields) == 0 {
+		fmt.Fprintln(out, "No changes found.")
+		return nil
+	}
+	width := len("Field")
+	for _, field := range fields {
+		if len(field) > width {
+			width = len(field)
+		}
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "%-*s  %-8s  %-16s  %s\n", width, "Field", "Ranges", "IPv4 addresses", "IPv6 addresses")
+	for _, field := range fields {
+		s := summaries[field]
+		fmt.Fprintf(out, "%-*s  %-8d  %-16s  %s\n", width, field, s.Ranges, s.IPv4Count, s.IPv6Count)
+	}
+	return nil
+}
+
+// MMDBDiffSource returns the MMDB networks to compare, the fields are the flattened record paths
+func MMDBDiffSource(rdr *maxminddb.Reader) DiffSource {
+	walk := func(fn diffRangeFunc) error {
+		return WalkMMDBRanges(rdr, func(start uint128, end uint128, record map[string]any) error {
+			fields := map[string]string{}
+			for _, f := range FlattenMMDBRecord("", record, nil) {
+				fields[f.Key] = fmt.Sprint(f.Value)
+			}
+			return fn(start, end, fields)
+		})
+	}
+	return DiffSource{Format: "MMDB", walk: walk}
+}
+
+// BINDiffSource returns the BIN ranges to compare, the fields are the CSV column names
+func BINDiffSource(rdr *BINReader) DiffSource {
+	names := rdr.ColumnNames()
+	ipv4Only := rdr.RowCount(true) == 0
+	walk := func(fn diffRangeFunc) error {
+		return WalkBINAsCSV(rdr, func(parts []string) error {
+			start, _ := parseUint128(parts[0])
+			end, _ := parseUint128(parts[1])
+			if ipv4Only { // same numbering as the IPv6 BIN
+				start = start.add(ipv4MappedStartNumber)
+				end = end.add(ipv4MappedStartNumber)
+			}
+			fields := map[string]string{}
+			for i, name := range names {
+				fields[name] = parts[i+2]
+			}
+			return fn(start, end, fields)
+		})
+	}
+	return DiffSource{Format: "BIN", names: names, walk: walk}
+}
+
+// StartDiffWalk runs the walk in the background so that the ranges of 2 files can be read in step. The gaps are
+// given with nil fields so that the ranges cover every IP number. Stops when done is closed.
+func StartDiffWalk(walk func(fn diffRangeFunc) error, done <-chan struct{}) <-chan diffRange {
+	ranges := make(chan diffRange, 64)
+	send := func(r diffRange) error {
+		select {
+		case ranges <- r:
+			return nil
+		case <-done:
+			return errDiffStopped
+		}
+	}
+
+	go func() {
+		defer close(ranges)
+		next := ipCursor{}
+		err := walk(func(start uint128, end uint128, fields map[string]string) error {
+			if !next.before(start) && start != next.next {
+				if err := send(diffRange{start: next.next, end: start.subOne(), done: true}); err != nil {
+					return err
+				}
+			}
+			next.advance(end)
+			return send(diffRange{start: start, end: end, fields: fields, done: true})
+		})
+		if err == errDiffStopped {
+			return
+		} else if err != nil {
+			send(diffRange{err: &IOError{Op: "Unable to read input file.", Err: err}})
+			return
+		}
+		if !next.end {
+			send(diffRange{start: next.next, end: maxIPv6Number, done: true})
+		}
+	}()
+	return ranges
+}
+
+// WalkMMDBRanges calls fn with the MMDB networks in the IP2Location IPv6 CSV order, the IPv4 networks are put
+// into the IPv4-mapped IPv6 range
+func WalkMMDBRanges(rdr *maxminddb.Reader, fn func(start uint128, end uint128, record map[string]any) error) error {
+	// the IPv4 networks come first from the reader so the first pass only does the IPv6 networks below them
+	networks := rdr.Networks(maxminddb.SkipAliasedNetworks)
+	for networks.Next() {
+		var record map[string]any
+		network, err := networks.Network(&record)
+		if err != nil {
+			return err
+		}
+		if len(network.IP) == net.IPv4len {
+			continue
+		}
+		start, end := networkRange(network)
+		if start.cmp(ipv4MappedStartNumber) >= 0 {
+			break
+		}
+		if end.cmp(ipv4MappedStartNumber) >= 0 {
+			end = ipv4MappedStartNumber.subOne()
+		}
+		if err = fn(start, end, record); err != nil {
+			return err
+		}
+	}
+	if networks.Err() != nil {
+		return networks.Err()
+	}
+
+	networks = rdr.Networks(maxminddb.SkipAliasedNetworks)
+	for networks.Next() {
+		var record map[string]any
+		network, err := networks.Network(&record)
+		if err != nil {
+			return err
+		}
+		start, end := networkRange(network)
+		if len(network.IP) == net.IPv4len {
+			start = start.add(ipv4MappedStartNumber)
+			end = end.add(ipv4MappedStartNumber)
+		} else if end.cmp(ipv4MappedEndNumber) <= 0 {
+			continue // already done in the first pass or is inside the IPv4-mapped range
+		} else if start.cmp(ipv4MappedEndNumber) <= 0 {
+			start = ipv4MappedEndNumber.addOne()
+		}
+		if err = fn(start, end, record); err != nil {
+			return err
+		}
+	}
+	return networks.Err()
+}
+
+// DiffFields returns the fields with different values, a missing or empty field is the same as "-"
+func DiffFields(oldFields map[string]string, newFields map[string]string, sortFields func(names []string)) []diffChange {
+	value := func(fields map[string]string, name string) string {
+		if v := fields[name]; v != "" {
+			return v
+		}
+		return "-"
+	}
+
+	names := []string{}
+	for name := range oldFields {
+		if value(oldFields, name) != value(newFields, name) {
+			names = append(names, name)
+		}
+	}
+	for name := range newFields {
+		if _, ok := oldFields[name]; !ok && value(newFields, name) != "-" {
+			names = append(names, name)
+		}
+	}
+	sortFields(names)
+
+	changes := []diffChange{}
+	for _, name := range names {
+		changes = append(changes, diffChange{Field: name, Old: value(oldFields, name), New: value(newFields, name)})
+	}
+	return changes
+}
+
+func EqualChanges(a []diffChange, b []diffChange) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffRangeIP returns the IP address for the IP number, IPv4-mapped IPv6 is returned as plain IPv4
+func DiffRangeIP(num uint128) string {
+	if num.ipv4Mapped() {
+		return num.sub(ipv4MappedStartNumber).ip().String()
+	}
+	return num.ip().String()
+}
@@ -0,0 +1,4 @@
+// Package convert converts between the IP2Location and IP2Proxy CSV and BIN files and the MMDB format. It is the
+// library behind the ip2convert command, the functions read from and write to the given readers and writers and
+// return the errors to the caller.
+package convert
@@ -0,0 +1,181 @@
+package convert
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error classes of the conversions, use errors.Is to check the class of a returned error
+var (
+	ErrColumnCount   = errors.New("Wrong number of CSV columns.")
+	ErrIPNumber      = errors.New("Invalid IP number.")
+	ErrNotContiguous = errors.New("IP ranges are not contiguous.")
+	ErrInvalidData   = errors.New("Invalid CSV data.")
+	ErrIO            = errors.New("Unable to read or write file.")
+	ErrIPFamily      = errors.New("Wrong IP family of the CSV.")
+	ErrOption        = errors.New("Invalid option.")
+)
+
+// ColumnCountError is returned when a CSV record does not have the number of columns of the package
+type ColumnCountError struct {
+	Package  string // DB or PX package like "DB9", or the kind of CSV like "ASN"
+	Expected []int  // allowed column counts
+	Found    int
+}
+
+func (e *ColumnCountError) Error() string {
+	counts := make([]string, len(e.Expected))
+	for i, v := range e.Expected {
+		counts[i] = fmt.Sprint(v)
+	}
+	if len(counts) > 2 {
+		counts = []string{counts[0] + " to " + counts[len(counts)-1]} // allowed counts are in order
+	}
+	return fmt.Sprintf("%s CSV should have %s columns.", e.Package, strings.Join(counts, " or "))
+}
+
+func (e *ColumnCountError) Is(target error) bool {
+	return target == ErrColumnCount
+}
+
+// IPNumberError is returned when the IP from or IP to column is not a valid IP number
+type IPNumberError struct {
+	Value string
+}
+
+func (e *IPNumberError) Error() string {
+	return fmt.Sprintf("Invalid IP number %v.", e.Value)
+}
+
+func (e *IPNumberError) Is(target error) bool {
+	return target == ErrIPNumber
+}
+
+// RangeError is returned when a CSV range does not start right after the previous range
+type RangeError struct {
+	Start    string // IP from of the range
+	Expected string // IP number after the previous range
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("IP range starts at %v instead of %v.", e.Start, e.Expected)
+}
+
+func (e *RangeError) Is(target error) bool {
+	return target == ErrNotContiguous
+}
+
+// IOError is returned when reading the input or writing the output fails
+type IOError struct {
+	Op  string // what failed, like "Unable to read input file."
+	Err error
+}
+
+func (e *IOError) Error() string {
+	return e.Op
+}
+
+func (e *IOError) Unwrap() error {
+	return e.Err
+}
+
+func (e *IOError) Is(target error) bool {
+	return target == ErrIO
+}
+
+// ParseError is returned with the CSV line number and record for the errors from a CSV record. Err is the cause,
+// use errors.Is on the ParseError to check the class of the cause.
+type ParseError struct {
+	Line   int      // line number in the CSV, 0 if not known
+	Record []string // the record as read from the CSV, nil if not known
+	Column string   // name of the column like "ip_from" or "latitude", empty if not about a single column
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column != "":
+		return fmt.Sprintf("Line %d, column %s: %v", e.Line, e.Column, e.Err)
+	case e.Line > 0:
+		return fmt.Sprintf("Line %d: %v", e.Line, e.Err)
+	case e.Column != "":
+		return fmt.Sprintf("Column %s: %v", e.Column, e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// columnError returns err for the CSV column, the line and record are added by lineError
+func columnError(column string, err error) error {
+	return &ParseError{Column: column, Err: err}
+}
+
+// lineError adds the CSV line number and record to err. I/O errors are not about the record and returned as is.
+func lineError(err error, line int, record []string) error {
+	if errors.Is(err, ErrIO) {
+		return err
+	}
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		if parseErr.Line == 0 {
+			parseErr.Line = line
+		}
+		if parseErr.Record == nil {
+			parseErr.Record = record
+		}
+		return parseErr
+	}
+	return &ParseError{Line: line, Record: record, Err: err}
+}
+
+// classError keeps its own message but matches the error class with errors.Is
+type classError struct {
+	class error
+	msg   string
+}
+
+func (e *classError) Error() string {
+	return e.msg
+}
+
+func (e *classError) Is(target error) bool {
+	return target == e.class
+}
+
+// newClassError returns an error with the message that matches the error class
+func newClassError(class error, format string, a ...any) error {
+	return &classError{class: class, msg: fmt.Sprintf(format, a...)}
+}
+
+// recordError keeps the classified errors from adding a CSV record, the rest are invalid data
+func recordError(err error) error {
+	if errors.Is(err, ErrIO) || errors.Is(err, ErrIPNumber) || errors.Is(err, ErrInvalidData) {
+		return err
+	}
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.Err = recordError(parseErr.Err)
+		return parseErr
+	}
+	return ErrInvalidData
+}
+
+// csvReadError returns the error for a failed csv.Reader Read, the CSV syntax errors are invalid data with the
+// csv.Reader reason. The record is the lines of the bad record as a single field if the reader kept them.
+func csvReadError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		var record []string
+		var syntaxErr *csvSyntaxError
+		if errors.As(err, &syntaxErr) {
+			record = []string{syntaxErr.lines}
+		}
+		return &ParseError{Line: parseErr.Line, Record: record, Err: newClassError(ErrInvalidData, "Invalid CSV data, %v.", parseErr.Err)}
+	}
+	return &IOError{Op: "Unable to read input file.", Err: err}
+}
@@ -0,0 +1,142 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var productNames = map[uint8]string{
+	0: "IP2Location", // older BIN files leave the product code as 0
+	1: "IP2Location",
+	2: "IP2Proxy",
+}
+
+var productTypeNames = map[uint8]string{
+	1: "commercial",
+	2: "LITE",
+	3: "generated",
+}
+
+// InfoField is a value in the info and lookup output
+type InfoField struct {
+	Key   string // JSON key
+	Label string // text label
+	Value any
+}
+
+// OpenDatabase opens the BIN or MMDB file, the file type is detected from the content. Only one of the readers
+// is returned.
+func OpenDatabase(input string) (*BINReader, *maxminddb.Reader, error) {
+	if _, err := os.Stat(input); err != nil {
+		return nil, nil, &IOError{Op: fmt.Sprintf("Invalid input file %v.", input), Err: err}
+	}
+	if mmdb, err := maxminddb.Open(input); err == nil {
+		return nil, mmdb, nil
+	}
+	if bin, err := OpenBIN(input); err == nil {
+		return bin, nil, nil
+	}
+	return nil, nil, newClassError(ErrInvalidData, "Unsupported file %v.", input)
+}
+
+// BINInfo returns the BIN header fields, the name is shown as the file
+func BINInfo(rdr *BINReader, name string) []InfoField {
+	h := rdr.Header
+	pkg := fmt.Sprintf("DB%d", h.DBType)
+	if h.ProductCode == 2 {
+		pkg = fmt.Sprintf("PX%d", h.DBType)
+	}
+	productType := productTypeNames[h.ProductType]
+	if productType == "" {
+		productType = "unknown"
+	}
+
+	return []InfoField{
+		{"file", "File", name},
+		{"format", "Format", "BIN"},
+		{"product", "Product", productNames[h.ProductCode]},
+		{"product_code", "Product code", h.ProductCode},
+		{"product_type", "Product type", h.ProductType},
+		{"product_type_name", "Product type name", productType},
+		{"package", "Package", pkg},
+		{"db_type", "DB type", h.DBType},
+		{"db_column", "DB columns", h.DBColl},
+		{"date", "Date", fmt.Sprintf("20%02d-%02d-%02d", h.DBYear, h.DBMonth, h.DBDay)},
+		{"ipv4_count", "IPv4 rows", h.IPv4Count},
+		{"ipv4_base", "IPv4 base", h.IPv4Base},
+		{"ipv6_count", "IPv6 rows", h.IPv6Count},
+		{"ipv6_base", "IPv6 base", h.IPv6Base},
+		{"ipv4_index_base", "IPv4 index base", h.IPv4IndexBase},
+		{"ipv6_index_base", "IPv6 index base", h.IPv6IndexBase},
+		{"file_size", "File size", h.FileSize},
+	}
+}
+
+// MMDBInfo returns the MMDB metadata fields, the name is shown as the file
+func MMDBInfo(rdr *maxminddb.Reader, name string) []InfoField {
+	m := rdr.Metadata
+
+	return []InfoField{
+		{"file", "File", name},
+		{"format", "Format", "MMDB"},
+		{"database_type", "Database type", m.DatabaseType},
+		{"description", "Description", m.Description},
+		{"languages", "Languages", m.Languages},
+		{"build_epoch", "Build epoch", m.BuildEpoch},
+		{"build_date", "Build date", time.Unix(int64(m.BuildEpoch), 0).UTC().Format(time.RFC3339)},
+		{"node_count", "Node count", m.NodeCount},
+		{"record_size", "Record size", m.RecordSize},
+		{"ip_version", "IP version", m.IPVersion},
+		{"binary_format", "Binary format", fmt.Sprintf("%d.%d", m.BinaryFormatMajorVersion, m.BinaryFormatMinorVersion)},
+	}
+}
+
+// PrintInfoText writes the fields as aligned "Label: value" lines
+func PrintInfoText(out io.Writer, fields []InfoField) {
+	width := 0
+	for _, f := range fields {
+		if len(f.Label)+2 > width {
+			width = len(f.Label) + 2 // colon and at least 1 space
+		}
+	}
+
+	for _, f := range fields {
+		value := fmt.Sprint(f.Value)
+		switch v := f.Value.(type) {
+		case []string:
+			value = strings.Join(v, ", ")
+		case map[string]string:
+			descriptions := []string{}
+			for lang, desc := range v {
+				descriptions = append(descriptions, lang+": "+desc)
+			}
+			sort.Strings(descriptions)
+			value = strings.Join(descriptions, ", ")
+		}
+		fmt.Fprintf(out, "%-*s%s\n", width, f.Label+":", value)
+	}
+}
+
+// PrintInfoJSON writes the fields as a JSON object line keeping the same order as the text output
+func PrintInfoJSON(out io.Writer, fields []InfoField) {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, f := range fields {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		key, _ := json.Marshal(f.Key)
+		value, _ := json.Marshal(f.Value)
+		sb.Write(key)
+		sb.WriteString(":")
+		sb.Write(value)
+	}
+	sb.WriteString("}")
+	fmt.Fprintln(out, sb.String())
+}
@@ -0,0 +1,100 @@
+package convert
+
+import (
+	"github.com/oschwald/maxminddb-golang"
+	"net"
+	"sort"
+	"strconv"
+)
+
+// LookupBIN returns the populated fields of the BIN row with the IP address, using the CSV column names
+func LookupBIN(rdr *BINReader, ip string) []InfoField {
+	fields := []InfoField{{"ip", "ip", ip}}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return append(fields, InfoField{"error", "error", "Invalid IP address."})
+	}
+
+	ipv6, row, found, err := rdr.FindRow(parsed)
+	if err != nil {
+		return append(fields, InfoField{"error", "error", err.Error()})
+	}
+	fields = append(fields, InfoField{"found", "found", found})
+	if !found {
+		return fields
+	}
+
+	start, values, err := rdr.ReadRow(ipv6, row)
+	if err != nil {
+		return append(fields, InfoField{"error", "error", err.Error()})
+	}
+	end, err := rdr.ReadIPFrom(ipv6, row+1)
+	if err != nil {
+		return append(fields, InfoField{"error", "error", err.Error()})
+	}
+	if row+2 < rdr.RowCount(ipv6) { // the ending record is the end of the last range itself
+		end = end.subOne()
+	}
+	fields = append(fields, InfoField{"ip_from", "ip_from", start.String()}, InfoField{"ip_to", "ip_to", end.String()})
+
+	for i, name := range rdr.ColumnNames() {
+		if values[i] != "-" && values[i] != "" {
+			fields = append(fields, InfoField{name, name, values[i]})
+		}
+	}
+	return fields
+}
+
+// LookupMMDB returns the record with the IP address, flattened into paths like "country.names.en"
+func LookupMMDB(rdr *maxminddb.Reader, ip string) []InfoField {
+	fields := []InfoField{{"ip", "ip", ip}}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return append(fields, InfoField{"error", "error", "Invalid IP address."})
+	}
+
+	var record any
+	network, found, err := rdr.LookupNetwork(parsed, &record)
+	if err != nil {
+		return append(fields, InfoField{"error", "error", "Unable to read input file."})
+	}
+	fields = append(fields, InfoField{"found", "found", found})
+	if !found {
+		return fields
+	}
+	fields = append(fields, InfoField{"network", "network", network.String()})
+
+	return FlattenMMDBRecord("", record, fields)
+}
+
+// FlattenMMDBRecord appends the values in the record with the keys joined by dots and the slice indexes as keys
+func FlattenMMDBRecord(prefix string, value any, fields []InfoField) []InfoField {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fields = FlattenMMDBRecord(JoinMMDBPath(prefix, key), v[key], fields)
+		}
+	case []any:
+		for i, item := range v {
+			fields = FlattenMMDBRecord(JoinMMDBPath(prefix, strconv.Itoa(i)), item, fields)
+		}
+	default:
+		fields = append(fields, InfoField{prefix, prefix, v})
+	}
+	return fields
+}
+
+// JoinMMDBPath returns the MMDB path with the key added
+func JoinMMDBPath(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,223 @@
+package convert
+
+import (
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MMDB paths for the BIN columns, the same fields that csv2mmdb writes. Alternative paths separated by "|" are
+// tried in order so that the GeoLite2-ASN and GeoIP2-ISP top level fields can be used too.
+var defaultMMDBColumnPaths = map[string]string{
+	"country_code":         "country.iso_code|registered_country.iso_code",
+	"country_name":         "country.names.en|registered_country.names.en",
+	"region":               "subdivisions.0.names.en",
+	"city":                 "city.names.en",
+	"latitude":             "location.latitude",
+	"longitude":            "location.longitude",
+	"zip_code":             "postal.code",
+	"time_zone":            "location.time_zone",
+	"isp":                  "traits.isp|isp",
+	"domain":               "traits.domain",
+	"net_speed":            "ip2location.net_speed",
+	"idd_code":             "ip2location.idd_code",
+	"area_code":            "ip2location.area_code",
+	"weather_station_code": "ip2location.weather_station_code",
+	"weather_station_name": "ip2location.weather_station_name",
+	"mcc":                  "traits.mobile_country_code|mobile_country_code",
+	"mnc":                  "traits.mobile_network_code|mobile_network_code",
+	"mobile_brand":         "ip2location.mobile_brand",
+	"elevation":            "ip2location.elevation",
+	"usage_type":           "ip2location.usage_type",
+	"address_type":         "ip2location.address_type",
+	"category":             "ip2location.category",
+	"district":             "ip2location.district",
+	"asn":                  "traits.autonomous_system_number|autonomous_system_number",
+	"as":                   "traits.autonomous_system_organization|autonomous_system_organization",
+}
+
+// MMDB2BINOptions are the options for ConvertMMDB2BIN
+type MMDB2BINOptions struct {
+	Package uint8  // 1 to 26 for the IP2Location DB package
+	Mapping string // column=path pairs separated by commas to replace the default MMDB paths
+
+	Date time.Time // date of the database in the BIN header, the MMDB build date if zero
+}
+
+// ConvertMMDB2BIN converts the MMDB networks into the IP2Location BIN for the DB package.
+func ConvertMMDB2BIN(rdr *maxminddb.Reader, out io.Writer, opts MMDB2BINOptions) error {
+	var err error
+
+	dbType := opts.Package
+	if dbType < 1 || dbType > 26 {
+		return newClassError(ErrOption, "Invalid DB package.")
+	}
+
+	columns, _ := BINColumns(1, dbType)
+	var paths [][]string
+	if paths, err = MMDBColumnPaths(columns, opts.Mapping); err != nil {
+		return err
+	}
+
+	ipv6 := rdr.Metadata.IPVersion == 6
+	date := opts.Date
+	if date.IsZero() {
+		date = time.Unix(int64(rdr.Metadata.BuildEpoch), 0).UTC()
+	}
+	empty := EmptyColumnFields(columns)
+	floats := make([]bool, len(empty))
+	i := 0
+	for _, col := range columns {
+		floats[i] = col.kind == columnFloat
+		if col.kind == columnCountry {
+			i++
+		}
+		i++
+	}
+
+	return WriteRangesBIN(out, 1, dbType, ipv6, date, func(fn binRangeFunc) error {
+		// the IPv4 networks come first from the reader and the networks are in order so only need to fill the gaps
+		ipv6Section := false
+		newWriter := func() *csvRangeWriter {
+			return &csvRangeWriter{empty: empty, emit: func(parts []string) error {
+				start, _ := parseUint128(parts[0])
+				end, _ := parseUint128(parts[1])
+				return fn(ipv6Section, start, end, parts[2:])
+			}}
+		}
+		wtr := newWriter()
+
+		networks := rdr.Networks(maxminddb.SkipAliasedNetworks)
+		for networks.Next() {
+			var record map[string]any
+			var network *net.IPNet
+			if network, err = networks.Network(&record); err != nil {
+				return &IOError{Op: "Unable to read input file.", Err: err}
+			}
+			if len(network.IP) != net.IPv4len && !ipv6Section {
+				if err = wtr.Close(maxIPv4Number); err != nil {
+					return err
+				}
+				ipv6Section = true
+				wtr = newWriter()
+			}
+
+			fields := make([]string, len(paths))
+			for i, path := range paths {
+				fields[i] = MMDBPathValue(record, path, floats[i])
+			}
+			start, end := networkRange(network)
+			wtr.Add(start, end, fields, true) // consecutive networks with the same data are merged into a single range
+		}
+		if err = networks.Err(); err != nil {
+			return &IOError{Op: "Unable to read input file.", Err: err}
+		}
+
+		if !ipv6Section {
+			if err = wtr.Close(maxIPv4Number); err != nil {
+				return err
+			}
+			if !ipv6 {
+				return nil
+			}
+			ipv6Section = true
+			wtr = newWriter()
+		}
+		return wtr.Close(maxIPv6Number)
+	})
+}
+
+// MMDBColumnPaths returns the MMDB paths to try for each field in the CSV column order
+func MMDBColumnPaths(columns []binColumn, mapping string) ([][]string, error) {
+	names := []string{}
+	for _, col := range columns {
+		if col.kind == columnCountry {
+			names = append(names, "country_code", "country_name")
+		} else {
+			names = append(names, col.name)
+		}
+	}
+
+	columnPaths := map[string]string{}
+	for _, name := range names {
+		columnPaths[name] = defaultMMDBColumnPaths[name]
+	}
+
+	for _, pair := range strings.Split(mapping, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, path, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		path = strings.TrimSpace(path)
+		if !ok || path == "" {
+			return nil, newClassError(ErrOption, "Invalid mapping %v.", pair)
+		}
+		if _, ok := columnPaths[name]; !ok {
+			return nil, newClassError(ErrOption, "Column %v is not in the DB package.", name)
+		}
+		columnPaths[name] = path
+	}
+
+	paths := [][]string{}
+	for _, name := range names {
+		paths = append(paths, strings.Split(columnPaths[name], "|"))
+	}
+	return paths, nil
+}
+
+// MMDBPathValue returns the value of the first path found in the record, like "subdivisions.0.names.en",
+// or the placeholder if none are found
+func MMDBPathValue(record map[string]any, paths []string, isFloat bool) string {
+	for _, path := range paths {
+		var value any = record
+		for _, key := range strings.Split(path, ".") {
+			if m, ok := value.(map[string]any); ok {
+				value = m[key]
+			} else if s, ok := value.([]any); ok {
+				if index, err := strconv.Atoi(key); err == nil && index >= 0 && index < len(s) {
+					value = s[index]
+				} else {
+					value = nil
+				}
+			} else {
+				value = nil
+			}
+			if value == nil {
+				break
+			}
+		}
+
+		switch v := value.(type) {
+		case nil:
+			continue
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			if isFloat {
+				return strconv.FormatFloat(v, 'f', 6, 64)
+			}
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case float32:
+			if isFloat {
+				return FormatCoordinate(v)
+			}
+			return strconv.FormatFloat(float64(v), 'f', -1, 32)
+		case map[string]any, []any:
+			continue
+		default:
+			return fmt.Sprint(v) // integers, booleans and big.Int for uint128
+		}
+	}
+
+	if isFloat {
+		return "0.000000"
+	}
+	return "-"
+}
@@ -0,0 +1,132 @@
+package convert
+
+import (
+	"bufio"
+	"github.com/oschwald/maxminddb-golang"
+	"io"
+	"net"
+	"strconv"
+)
+
+type mmdbNames struct {
+	Names map[string]string `maxminddb:"names"`
+}
+
+type mmdbCountry struct {
+	IsoCode string            `maxminddb:"iso_code"`
+	Names   map[string]string `maxminddb:"names"`
+}
+
+type mmdbCityRecord struct {
+	Country           mmdbCountry `maxminddb:"country"`
+	RegisteredCountry mmdbCountry `maxminddb:"registered_country"`
+	Subdivisions      []mmdbNames `maxminddb:"subdivisions"`
+	City              mmdbNames   `maxminddb:"city"`
+	Location          struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+	Postal struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"postal"`
+}
+
+// ConvertMMDB2CSV writes the MMDB networks as the IP2Location DB1 or DB9 IPv6 CSV for the country or city MMDB type.
+func ConvertMMDB2CSV(rdr *maxminddb.Reader, out io.Writer, opts MMDBOptions) error {
+	var err error
+
+	mmdbType := opts.Type
+	if mmdbType != "country" && mmdbType != "city" {
+		return newClassError(ErrOption, "Invalid MMDB type.")
+	}
+
+	empty := []string{"-", "-"}
+	if mmdbType == "city" {
+		empty = append(empty, "-", "-", "0.000000", "0.000000", "-")
+	}
+
+	outFileBuffered := bufio.NewWriterSize(out, 65536)
+	wtr := &csvRangeWriter{empty: empty}
+	wtr.emit = func(parts []string) error {
+		WriteCSVRecord(outFileBuffered, parts)
+		return nil
+	}
+
+	// the IPv4 networks come first from the reader but they need to go into the IPv4-mapped IPv6 range,
+	// so first pass only outputs the IPv6 networks below that range (if any)
+	networks := rdr.Networks(maxminddb.SkipAliasedNetworks)
+	for networks.Next() {
+		var record mmdbCityRecord
+		var network *net.IPNet
+		if network, err = networks.Network(&record); err != nil {
+			return &IOError{Op: "Unable to read input file.", Err: err}
+		}
+		if len(network.IP) == net.IPv4len {
+			continue
+		}
+		start, end := networkRange(network)
+		if start.cmp(ipv4MappedStartNumber) >= 0 {
+			break
+		}
+		if end.cmp(ipv4MappedStartNumber) >= 0 {
+			end = ipv4MappedStartNumber.subOne()
+		}
+		wtr.Add(start, end, MMDBRecordToFields(&record, mmdbType), true)
+	}
+	if err = networks.Err(); err != nil {
+		return &IOError{Op: "Unable to read input file.", Err: err}
+	}
+
+	networks = rdr.Networks(maxminddb.SkipAliasedNetworks)
+	for networks.Next() {
+		var record mmdbCityRecord
+		var network *net.IPNet
+		if network, err = networks.Network(&record); err != nil {
+			return &IOError{Op: "Unable to read input file.", Err: err}
+		}
+		start, end := networkRange(network)
+		if len(network.IP) == net.IPv4len {
+			start = start.add(ipv4MappedStartNumber)
+			end = end.add(ipv4MappedStartNumber)
+		} else if end.cmp(ipv4MappedEndNumber) <= 0 {
+			continue // already done in the first pass or is inside the IPv4-mapped range
+		} else if start.cmp(ipv4MappedEndNumber) <= 0 {
+			start = ipv4MappedEndNumber.addOne()
+		}
+		// consecutive networks with the same data are merged into a single range
+		wtr.Add(start, end, MMDBRecordToFields(&record, mmdbType), true)
+	}
+	if err = networks.Err(); err != nil {
+		return &IOError{Op: "Unable to read input file.", Err: err}
+	}
+	wtr.Close(maxIPv6Number)
+
+	if err = outFileBuffered.Flush(); err != nil {
+		return &IOError{Op: "Writing to output file failed.", Err: err}
+	}
+	return SyncOutput(out)
+}
+
+// MMDBRecordToFields returns the DB1 or DB9 CSV columns after the IP from and IP to columns
+func MMDBRecordToFields(record *mmdbCityRecord, mmdbType string) []string {
+	country := record.Country
+	if country.IsoCode == "" {
+		country = record.RegisteredCountry // some networks like satellite providers only have this
+	}
+	fields := []string{DashIfEmpty(country.IsoCode), DashIfEmpty(country.Names["en"])}
+
+	if mmdbType == "city" {
+		region := ""
+		if len(record.Subdivisions) > 0 {
+			region = record.Subdivisions[0].Names["en"]
+		}
+		lat := "0.000000"
+		long := "0.000000"
+		if record.Location.Latitude != nil && record.Location.Longitude != nil {
+			lat = strconv.FormatFloat(*record.Location.Latitude, 'f', 6, 64)
+			long = strconv.FormatFloat(*record.Location.Longitude, 'f', 6, 64)
+		}
+		fields = append(fields, DashIfEmpty(region), DashIfEmpty(record.City.Names["en"]), lat, long, DashIfEmpty(record.Postal.Code))
+	}
+	return fields
+}
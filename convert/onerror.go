@@ -0,0 +1,194 @@
+package convert
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// RecordErrorFunc is called with the error of a bad CSV record when set as the OnError option. Returning nil
+// leaves the record out and continues the conversion, returning an error stops the conversion with the error.
+type RecordErrorFunc func(err *ParseError) error
+
+// SkipRecords leaves out all the bad CSV records
+func SkipRecords(err *ParseError) error {
+	return nil
+}
+
+// QuarantineRecords leaves out the bad CSV records and writes them to out as CSV, each with the line number and
+// the reason before the fields of the record
+func QuarantineRecords(out io.Writer) RecordErrorFunc {
+	w := csv.NewWriter(out)
+	return func(err *ParseError) error {
+		reason := &ParseError{Column: err.Column, Err: err.Err} // without the line number which has its own column
+		w.Write(append([]string{strconv.Itoa(err.Line), reason.Error()}, err.Record...))
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return &IOError{Op: "Writing to quarantine file failed.", Err: err}
+		}
+		return nil
+	}
+}
+
+// recordErrorHandler passes the errors of the bad CSV records to the OnError option. The CSV can be read more than
+// once so each line is only passed in the first read.
+type recordErrorHandler struct {
+	onError  RecordErrorFunc
+	lastLine int
+}
+
+// handle returns nil if the bad record is left out by the OnError option, otherwise the error to stop with.
+// I/O errors and the errors not about a CSV line always stop the conversion.
+func (h *recordErrorHandler) handle(err error) error {
+	if h.onError == nil || errors.Is(err, ErrIO) {
+		return err
+	}
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line == 0 {
+		return err
+	}
+	if parseErr.Line <= h.lastLine {
+		return nil // already passed in an earlier read of the CSV
+	}
+	h.lastLine = parseErr.Line
+	return h.onError(parseErr)
+}
+
+// csvRecordReader is the csv.Reader methods used by the converters
+type csvRecordReader interface {
+	Read() ([]string, error)
+	FieldPos(field int) (line int, column int)
+}
+
+// lenientCSVReader reads the IP2Location CSV for WriteBIN with the OnError option. The bad records are checked
+// before WriteBIN sees them and left out, and the gaps they leave are filled with "-" records so the BIN still
+// covers all the IP addresses.
+type lenientCSVReader struct {
+	rdr         csvRecordReader
+	handler     *recordErrorHandler
+	dbType      uint8
+	dbColl      uint8
+	maxIP       uint128
+	ipFamily    string
+	ipv6        bool     // a record has IP numbers past IPv4, even if left out
+	next        ipCursor // IP number after the last returned range
+	line        int      // line of the last returned record, 0 for the filled gaps
+	pending     []string // record to return after the filled gap
+	pendingLine int
+}
+
+// newLenientCSVReader returns the reader of the records from csvRdr for the IP family, or for either family if
+// ipFamily is blank
+func newLenientCSVReader(csvRdr csvRecordReader, dbType uint8, ipFamily string, handler *recordErrorHandler) *lenientCSVReader {
+	maxIP := maxIPv6Number
+	if ipFamily == "4" {
+		maxIP = maxIPv4Number
+	}
+	return &lenientCSVReader{rdr: csvRdr, handler: handler, dbType: dbType, dbColl: columnSize[dbType], maxIP: maxIP, ipFamily: ipFamily}
+}
+
+func (r *lenientCSVReader) FieldPos(field int) (int, int) {
+	return r.line, 0
+}
+
+func (r *lenientCSVReader) Read() ([]string, error) {
+	if r.pending != nil {
+		parts := r.pending
+		r.line = r.pendingLine
+		r.pending = nil
+		return parts, nil
+	}
+
+	for {
+		parts, err := r.rdr.Read()
+		if err == io.EOF {
+			last := r.maxIP
+			if r.ipFamily == "" && !r.ipv6 { // IPv4 CSV
+				last = maxIPv4Number
+			}
+			if r.ipFamily == "6" && !r.ipv6 {
+				return nil, ipFamilyError(r.ipFamily) // the IPv4 CSV would only be filled up with "-" records
+			}
+			if !r.next.before(last) { // fill up to the last IP address
+				gap := r.gapRecord(r.next.next, last)
+				r.next.advance(last)
+				r.line = 0
+				return gap, nil
+			}
+			return nil, io.EOF
+		} else if err != nil {
+			err = csvReadError(err)
+		} else {
+			line, _ := r.rdr.FieldPos(0)
+			ips := recordIPRange(r.rdr, parts)
+			r.ipv6 = r.ipv6 || ips.hasIPv6Number()
+			if r.ipFamily == "4" && ips.startOK && ips.endOK && ips.end.cmp(maxIPv4Number) > 0 && ips.start.cmp(ips.end) <= 0 {
+				return nil, lineError(ipFamilyError(r.ipFamily), line, parts) // not a bad record, the whole CSV is the wrong one
+			}
+			var start, end uint128
+			if start, end, err = r.check(parts, ips); err == nil {
+				next := r.next.next
+				r.next.advance(end)
+				if start.cmp(next) > 0 {
+					r.pending = parts
+					r.pendingLine = line
+					r.line = 0
+					return r.gapRecord(next, start.subOne()), nil
+				}
+				r.line = line
+				return parts, nil
+			}
+			err = lineError(err, line, parts)
+		}
+
+		if err = r.handler.handle(err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// check returns the range of the record if WriteBIN can convert it
+func (r *lenientCSVReader) check(parts []string, ips csvIPRange) (uint128, uint128, error) {
+	var none uint128
+	if len(parts) != int(r.dbColl)+2 {
+		return none, none, &ColumnCountError{Package: "DB" + strconv.Itoa(int(r.dbType)), Expected: []int{int(r.dbColl) + 2}, Found: len(parts)}
+	}
+
+	start, end := ips.start, ips.end
+	if !ips.startOK || start.cmp(r.maxIP) > 0 {
+		return none, none, columnError("ip_from", &IPNumberError{Value: parts[0]})
+	}
+	if !ips.endOK || end.cmp(start) < 0 || end.cmp(r.maxIP) > 0 {
+		return none, none, columnError("ip_to", &IPNumberError{Value: parts[1]})
+	}
+	if r.next.before(start) {
+		return none, none, columnError("ip_from", &RangeError{Start: parts[0], Expected: r.next.String()})
+	}
+
+	if latitudePosition[r.dbType] > 0 {
+		if _, err := strconv.ParseFloat(parts[latitudePosition[r.dbType]+1], 64); err != nil {
+			return none, none, columnError("latitude", newClassError(ErrInvalidData, "Invalid latitude %v.", parts[latitudePosition[r.dbType]+1]))
+		}
+	}
+	if longitudePosition[r.dbType] > 0 {
+		if _, err := strconv.ParseFloat(parts[longitudePosition[r.dbType]+1], 64); err != nil {
+			return none, none, columnError("longitude", newClassError(ErrInvalidData, "Invalid longitude %v.", parts[longitudePosition[r.dbType]+1]))
+		}
+	}
+	return start, end, nil
+}
+
+// gapRecord returns a "-" record for the range, the coordinates are 0 like the unassigned ranges in the LITE CSV
+func (r *lenientCSVReader) gapRecord(start uint128, end uint128) []string {
+	parts := EmptyCSVRecord(r.dbColl)
+	parts[0] = start.String()
+	parts[1] = end.String()
+	if latitudePosition[r.dbType] > 0 {
+		parts[latitudePosition[r.dbType]+1] = "0.000000"
+	}
+	if longitudePosition[r.dbType] > 0 {
+		parts[longitudePosition[r.dbType]+1] = "0.000000"
+	}
+	return parts
+}
@@ -0,0 +1,121 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// recordStore keeps the parsed CSV records in a temp file so that WriteBIN only parses the CSV once. Reading the
+// records back is much cheaper than parsing the CSV again and nothing is kept in memory.
+type recordStore struct {
+	file *os.File
+	w    *bufio.Writer
+	buf  []byte
+}
+
+func newRecordStore() (*recordStore, error) {
+	file, err := os.CreateTemp("", "ip2convert-*.tmp")
+	if err != nil {
+		return nil, &IOError{Op: "Unable to create temp file.", Err: err}
+	}
+	return &recordStore{file: file, w: bufio.NewWriterSize(file, 65536)}, nil
+}
+
+// Write adds the record at the CSV line. The line and the field lengths come first, then the fields.
+func (s *recordStore) Write(line int, parts []string) error {
+	buf := s.buf[:0]
+	buf = appendUvarint(buf, uint64(line))
+	buf = appendUvarint(buf, uint64(len(parts)))
+	for _, v := range parts {
+		buf = appendUvarint(buf, uint64(len(v)))
+	}
+	for _, v := range parts {
+		buf = append(buf, v...)
+	}
+	s.buf = buf
+
+	if _, err := s.w.Write(buf); err != nil {
+		return &IOError{Op: "Unable to write temp file.", Err: err}
+	}
+	return nil
+}
+
+// Reader returns the reader for the records from the start, the records cannot be added after
+func (s *recordStore) Reader() (*recordStoreReader, error) {
+	if err := s.w.Flush(); err != nil {
+		return nil, &IOError{Op: "Unable to write temp file.", Err: err}
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, &IOError{Op: "Unable to read temp file.", Err: err}
+	}
+	return &recordStoreReader{r: bufio.NewReaderSize(s.file, 65536)}, nil
+}
+
+// Close deletes the temp file
+func (s *recordStore) Close() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// recordStoreReader reads the records of the recordStore in the same way as a csv.Reader
+type recordStoreReader struct {
+	r     *bufio.Reader
+	line  int
+	sizes []int
+	buf   []byte
+}
+
+// Read returns the next record, the record can be changed by the caller
+func (r *recordStoreReader) Read() ([]string, error) {
+	line, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, &IOError{Op: "Unable to read temp file.", Err: err}
+	}
+	count, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, &IOError{Op: "Unable to read temp file.", Err: err}
+	}
+
+	r.sizes = r.sizes[:0]
+	total := 0
+	for i := uint64(0); i < count; i++ {
+		n, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return nil, &IOError{Op: "Unable to read temp file.", Err: err}
+		}
+		r.sizes = append(r.sizes, int(n))
+		total += int(n)
+	}
+	if cap(r.buf) < total {
+		r.buf = make([]byte, total)
+	}
+	if _, err = io.ReadFull(r.r, r.buf[:total]); err != nil {
+		return nil, &IOError{Op: "Unable to read temp file.", Err: err}
+	}
+
+	// like csv.Reader the fields share the memory of one string
+	str := string(r.buf[:total])
+	parts := make([]string, count)
+	for i, n := range r.sizes {
+		parts[i] = str[:n]
+		str = str[n:]
+	}
+	r.line = int(line)
+	return parts, nil
+}
+
+// FieldPos returns the CSV line of the last record read
+func (r *recordStoreReader) FieldPos(field int) (int, int) {
+	return r.line, 0
+}
+
+// appendUvarint appends the varint encoding of x to buf
+func appendUvarint(buf []byte, x uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], x)
+	return append(buf, b[:n]...)
+}
@@ -0,0 +1,267 @@
+package convert
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+	"net"
+	"strconv"
+)
+
+// uint128 is an IP number as a fixed-width integer, parsing and comparing it does not allocate like big.Int so
+// it is used for the work done on every CSV row
+type uint128 struct {
+	hi uint64
+	lo uint64
+}
+
+var maxIPv4Number = uint128{lo: 4294967295}
+var maxIPv6Number = uint128{hi: ^uint64(0), lo: ^uint64(0)}
+
+// the IPv4-mapped IPv6 range ::ffff:0.0.0.0 to ::ffff:255.255.255.255
+var ipv4MappedStartNumber = uint128{lo: 281470681743360}
+var ipv4MappedEndNumber = uint128{lo: 281474976710655}
+
+// parseUint128 parses the decimal number, accepting the same strings as big.Int SetString with base 10. ok is
+// false if the number is negative or does not fit in 128 bits.
+func parseUint128(s string) (n uint128, ok bool) {
+	neg := false
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return uint128{}, false
+	}
+
+	// the first 19 digits always fit in a uint64
+	i := 0
+	for ; i < len(s) && i < 19; i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return uint128{}, false
+		}
+		n.lo = n.lo*10 + uint64(c-'0')
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return uint128{}, false
+		}
+		// n = n*10 + digit, any carry out of the high word is an overflow
+		over, hi := bits.Mul64(n.hi, 10)
+		loCarry, lo := bits.Mul64(n.lo, 10)
+		hi, carry1 := bits.Add64(hi, loCarry, 0)
+		lo, digitCarry := bits.Add64(lo, uint64(c-'0'), 0)
+		hi, carry2 := bits.Add64(hi, digitCarry, 0)
+		if over|carry1|carry2 != 0 {
+			return uint128{}, false
+		}
+		n = uint128{hi: hi, lo: lo}
+	}
+	if neg && (n.hi != 0 || n.lo != 0) {
+		return uint128{}, false
+	}
+	return n, true
+}
+
+// uint128FromBytes returns the big endian IP address bytes, 4 bytes for IPv4 or 16 bytes for IPv6, as uint128
+func uint128FromBytes(b []byte) uint128 {
+	if len(b) == 16 {
+		return uint128{hi: binary.BigEndian.Uint64(b[:8]), lo: binary.BigEndian.Uint64(b[8:])}
+	}
+	return uint128{lo: uint64(binary.BigEndian.Uint32(b))}
+}
+
+// bigInt returns the number as big.Int for the sums that can go past 128 bits
+func (n uint128) bigInt() *big.Int {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], n.hi)
+	binary.BigEndian.PutUint64(buf[8:], n.lo)
+	return new(big.Int).SetBytes(buf[:])
+}
+
+func (n uint128) cmp(m uint128) int {
+	switch {
+	case n.hi < m.hi:
+		return -1
+	case n.hi > m.hi:
+		return 1
+	case n.lo < m.lo:
+		return -1
+	case n.lo > m.lo:
+		return 1
+	}
+	return 0
+}
+
+// addOne returns n + 1, wrapping to 0 after the last IPv6 number
+func (n uint128) addOne() uint128 {
+	lo, carry := bits.Add64(n.lo, 1, 0)
+	return uint128{hi: n.hi + carry, lo: lo}
+}
+
+// subOne returns n - 1, wrapping to the last IPv6 number before 0
+func (n uint128) subOne() uint128 {
+	lo, borrow := bits.Sub64(n.lo, 1, 0)
+	return uint128{hi: n.hi - borrow, lo: lo}
+}
+
+// add returns n + m, wrapping after the last IPv6 number
+func (n uint128) add(m uint128) uint128 {
+	lo, carry := bits.Add64(n.lo, m.lo, 0)
+	return uint128{hi: n.hi + m.hi + carry, lo: lo}
+}
+
+// sub returns n - m, wrapping before 0
+func (n uint128) sub(m uint128) uint128 {
+	lo, borrow := bits.Sub64(n.lo, m.lo, 0)
+	return uint128{hi: n.hi - m.hi - borrow, lo: lo}
+}
+
+// String returns the decimal number like big.Int String
+func (n uint128) String() string {
+	if n.hi == 0 {
+		return strconv.FormatUint(n.lo, 10)
+	}
+
+	// split into chunks of 19 digits, the most that fit in a uint64
+	const chunk = 10000000000000000000
+	var parts []uint64
+	for n.hi != 0 {
+		var rem uint64
+		n.hi, rem = n.hi/chunk, n.hi%chunk
+		n.lo, rem = bits.Div64(rem, n.lo, chunk)
+		parts = append(parts, rem)
+	}
+	buf := strconv.AppendUint(make([]byte, 0, 39), n.lo, 10)
+	for i := len(parts) - 1; i >= 0; i-- {
+		digits := strconv.FormatUint(parts[i], 10)
+		for j := len(digits); j < 19; j++ {
+			buf = append(buf, '0')
+		}
+		buf = append(buf, digits...)
+	}
+	return string(buf)
+}
+
+// ipv4 returns the IPv4 number if n is an IPv4 number or an IPv4-mapped IPv6 number (::ffff:0:0/96), which is
+// how net.IP formats them
+func (n uint128) ipv4() (uint32, bool) {
+	if n.hi == 0 && (n.lo <= 0xffffffff || n.lo>>32 == 0xffff) {
+		return uint32(n.lo), true
+	}
+	return 0, false
+}
+
+// first2Octet returns the first 2 bytes of the IPv6 number for the BIN index
+func (n uint128) first2Octet() uint32 {
+	return uint32(n.hi >> 48)
+}
+
+// ip returns the IP address for the IP number, 4 bytes for the IPv4 numbers otherwise 16 bytes
+func (n uint128) ip() net.IP {
+	if n.hi == 0 && n.lo <= 0xffffffff {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, uint32(n.lo))
+		return ip
+	}
+	return n.ipv6()
+}
+
+// ipv6 returns the 16 bytes IP address for the IP number
+func (n uint128) ipv6() net.IP {
+	ip := make(net.IP, 16)
+	binary.BigEndian.PutUint64(ip[:8], n.hi)
+	binary.BigEndian.PutUint64(ip[8:], n.lo)
+	return ip
+}
+
+// ipv4Mapped returns true if n is in the IPv4-mapped IPv6 range
+func (n uint128) ipv4Mapped() bool {
+	return n.cmp(ipv4MappedStartNumber) >= 0 && n.cmp(ipv4MappedEndNumber) <= 0
+}
+
+// littleEndian returns the 16 bytes of the IPv6 number as written in the BIN
+func (n uint128) littleEndian() []byte {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint64(b[:8], n.lo)
+	binary.LittleEndian.PutUint64(b[8:], n.hi)
+	return b
+}
+
+// ipv4LittleEndian returns the 4 bytes of the IPv4 number as written in the BIN
+func ipv4LittleEndian(n uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, n)
+	return b
+}
+
+// networkRange returns the first and last IP number of the network
+func networkRange(network *net.IPNet) (uint128, uint128) {
+	ip := network.IP
+	if v4 := ip.To4(); v4 != nil && len(network.Mask) == net.IPv4len {
+		ip = v4
+	}
+
+	lastIP := make(net.IP, len(ip))
+	for i := range ip {
+		lastIP[i] = ip[i] | ^network.Mask[i]
+	}
+	return uint128FromBytes(ip.Mask(network.Mask)), uint128FromBytes(lastIP)
+}
+
+// ipCursor is the IP number after the last CSV range, the ranges must continue from there
+type ipCursor struct {
+	next uint128
+	end  bool // the last range ended at the last IPv6 number so no more IP numbers are left
+}
+
+// advance moves the cursor after the range ending at last
+func (c *ipCursor) advance(last uint128) {
+	if last == maxIPv6Number {
+		c.end = true
+	}
+	c.next = last.addOne()
+}
+
+// before returns true if n is before the cursor, which is always the case once all the IP numbers are covered
+func (c ipCursor) before(n uint128) bool {
+	return c.end || n.cmp(c.next) < 0
+}
+
+// pastIPv4 returns true if the ranges so far went past the IPv4 numbers
+func (c ipCursor) pastIPv4() bool {
+	return c.end || c.next.cmp(maxIPv4Number.addOne()) > 0
+}
+
+func (c ipCursor) String() string {
+	if c.end {
+		return "340282366920938463463374607431768211456"
+	}
+	return c.next.String()
+}
+
+// csvIPRange is the IP from and IP to of a CSV record, the numbers are only valid if ok
+type csvIPRange struct {
+	start   uint128
+	end     uint128
+	startOK bool
+	endOK   bool
+}
+
+// parseCSVIPRange parses the IP from and IP to of the record, the missing columns are not ok
+func parseCSVIPRange(parts []string) (ips csvIPRange) {
+	if len(parts) > 0 {
+		ips.start, ips.startOK = parseUint128(parts[0])
+	}
+	if len(parts) > 1 {
+		ips.end, ips.endOK = parseUint128(parts[1])
+	}
+	return ips
+}
+
+// hasIPv6Number returns true if the IP from or IP to is a valid number past the IPv4 numbers
+func (ips csvIPRange) hasIPv6Number() bool {
+	return (ips.startOK && ips.start.cmp(maxIPv4Number) > 0) || (ips.endOK && ips.end.cmp(maxIPv4Number) > 0)
+}
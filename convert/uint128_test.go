@@ -0,0 +1,514 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// the big.Int and string helpers csv2bin used before the uint128 helpers, kept as the reference for the tests and
+// the benchmarks
+var refMaxIPv4Range = big.NewInt(4294967295)
+var refMaxIPv6Range, _ = new(big.Int).SetString("340282366920938463463374607431768211455", 10)
+
+func refDecimalToIPv4(IPNum *big.Int) (net.IP, error) {
+	if IPNum == nil || IPNum.Cmp(big.NewInt(0)) < 0 || IPNum.Cmp(refMaxIPv4Range) > 0 {
+		return nil, errors.New("Invalid IP number.")
+	}
+
+	buf := make([]byte, 4)
+	bytes := IPNum.FillBytes(buf)
+
+	ip := net.IP(bytes)
+	return ip, nil
+}
+
+func refDecimalToIPv6(IPNum *big.Int) (net.IP, error) {
+	if IPNum == nil || IPNum.Cmp(big.NewInt(0)) < 0 || IPNum.Cmp(refMaxIPv6Range) > 0 {
+		return nil, errors.New("Invalid IP number.")
+	}
+
+	buf := make([]byte, 16)
+	bytes := IPNum.FillBytes(buf)
+
+	ip := net.IP(bytes)
+	return ip, nil
+}
+
+func refGetIPv4First2Octet(bigStr string) (uint32, error) {
+	bigNum := new(big.Int)
+	ok := false
+	if bigNum, ok = bigNum.SetString(bigStr, 10); !ok {
+		return 0, errors.New("Error parsing IP number.")
+	}
+
+	buf := make([]byte, 4)
+	bigBytes := bigNum.FillBytes(buf) // need to fill into buffer to preserve all bytes instead of reading bigNum.Bytes()
+
+	var res uint32 = uint32(bigBytes[0])*256 + uint32(bigBytes[1])
+	return res, nil
+}
+
+func refGetIPv6First2Octet(bigStr string) (uint32, error) {
+	bigNum := new(big.Int)
+	ok := false
+	if bigNum, ok = bigNum.SetString(bigStr, 10); !ok {
+		return 0, errors.New("Error parsing IP number.")
+	}
+
+	buf := make([]byte, 16)
+	bigBytes := bigNum.FillBytes(buf) // need to fill into buffer to preserve all bytes instead of reading bigNum.Bytes()
+
+	var res uint32 = uint32(bigBytes[0])*256 + uint32(bigBytes[1])
+	return res, nil
+}
+
+func refForceAsIPv6(bigStr string) ([]byte, error) {
+	bigNum := new(big.Int)
+	ok := false
+	if bigNum, ok = bigNum.SetString(bigStr, 10); !ok {
+		return nil, errors.New("Error parsing IP number.")
+	}
+
+	buf := make([]byte, 16)
+	bigBytes := bigNum.FillBytes(buf) // need to fill into buffer to preserve all bytes instead of reading bigNum.Bytes()
+
+	return bigBytes, nil
+}
+
+func refIsIPv4(IP string) bool {
+	ipaddr := net.ParseIP(IP)
+
+	if ipaddr == nil {
+		return false
+	}
+
+	v4 := ipaddr.To4()
+
+	if v4 == nil {
+		return false
+	}
+
+	return true
+}
+
+func refIPv4ToDecimal(IP string) (*big.Int, error) {
+	if !refIsIPv4(IP) {
+		return nil, errors.New("Not a valid IPv4 address.")
+	}
+
+	ipnum := big.NewInt(0)
+	ipaddr := net.ParseIP(IP)
+
+	if ipaddr != nil {
+		v4 := ipaddr.To4()
+
+		if v4 != nil {
+			ipnum.SetBytes(v4)
+		}
+	}
+
+	return ipnum, nil
+}
+
+func refReverseBytes(s []byte) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+func refIPv4ToBytes(IP string) ([]byte, error) {
+	ipaddr := net.ParseIP(IP)
+
+	if ipaddr == nil {
+		return nil, errors.New("Invalid IP")
+	}
+
+	v4 := ipaddr.To4()
+
+	if v4 == nil {
+		return nil, errors.New("Bad IPv4")
+	}
+
+	return v4, nil
+}
+
+func refIPv6ToBytes(IP string) ([]byte, error) {
+	ipaddr := net.ParseIP(IP)
+
+	if ipaddr == nil {
+		return nil, errors.New("Invalid IP")
+	}
+
+	v6 := ipaddr.To16()
+
+	if v6 == nil {
+		return nil, errors.New("Bad IPv6")
+	}
+
+	return v6, nil
+}
+
+// refParse returns the IP number like the big.Int helpers took it, ok is false if it is not an IP number
+func refParse(s string) (*big.Int, bool) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.Cmp(refMaxIPv6Range) > 0 {
+		return nil, false
+	}
+	return n, true
+}
+
+// rowIPWork is the IP number work WriteBIN does for a CSV row in its 2 passes. The IP numbers of IPv4-mapped IPv6
+// are changed to IPv4, the first 2 octets of the range go in the index and ip_from is written in little endian.
+type rowIPWork struct {
+	from      string // ip_from and ip_to after the IPv4-mapped IPv6 numbers are changed to IPv4
+	to        string
+	fromIPv4  bool
+	toIPv4    bool
+	no2From   uint32
+	no2To     uint32
+	fromBytes []byte
+}
+
+// newRowIPWork does the work of WriteBIN with the uint128 helpers
+func newRowIPWork(from string, to string) (w rowIPWork, ok bool) {
+	for pass := 0; pass < 2; pass++ { // the second pass parses the record again
+		startNum, startOK := parseUint128(from)
+		endNum, endOK := parseUint128(to)
+		if !startOK || !endOK {
+			return w, false
+		}
+		var startIPv4, endIPv4 uint32
+		startIPv4, w.fromIPv4 = startNum.ipv4()
+		endIPv4, w.toIPv4 = endNum.ipv4()
+		w.from, w.to = from, to
+		if w.fromIPv4 {
+			w.from = strconv.FormatUint(uint64(startIPv4), 10)
+		}
+		if w.toIPv4 {
+			w.to = strconv.FormatUint(uint64(endIPv4), 10)
+		}
+
+		if pass == 0 {
+			switch {
+			case w.fromIPv4 && w.toIPv4:
+				w.no2From, w.no2To = startIPv4>>16, endIPv4>>16
+			case !w.fromIPv4 && !w.toIPv4:
+				w.no2From, w.no2To = startNum.first2Octet(), endNum.first2Octet()
+			case w.fromIPv4:
+				w.no2From, w.no2To = startIPv4>>16, uint32(maxIPv4Number.lo>>16)
+			}
+		} else if w.fromIPv4 {
+			w.fromBytes = ipv4LittleEndian(startIPv4)
+		} else {
+			w.fromBytes = startNum.littleEndian()
+		}
+	}
+	return w, true
+}
+
+// refRowIPWork does the work of WriteBIN with the big.Int helpers it used before
+func refRowIPWork(from string, to string) (w rowIPWork, ok bool) {
+	for pass := 0; pass < 2; pass++ {
+		startNum, startOK := new(big.Int).SetString(from, 10)
+		endNum, endOK := new(big.Int).SetString(to, 10)
+		if !startOK || !endOK {
+			return w, false
+		}
+		startIP, err := refDecimalToIPv4(startNum)
+		if err != nil {
+			if startIP, err = refDecimalToIPv6(startNum); err != nil {
+				return w, false
+			}
+		}
+		startIPStr := startIP.String() // plain IPv4 from IPv4-mapped IPv6
+		endIP, err := refDecimalToIPv4(endNum)
+		if err != nil {
+			if endIP, err = refDecimalToIPv6(endNum); err != nil {
+				return w, false
+			}
+		}
+		endIPStr := endIP.String()
+
+		w.from, w.to = from, to
+		if w.fromIPv4 = refIsIPv4(startIPStr); w.fromIPv4 {
+			n, _ := refIPv4ToDecimal(startIPStr)
+			w.from = n.String()
+		}
+		if w.toIPv4 = refIsIPv4(endIPStr); w.toIPv4 {
+			n, _ := refIPv4ToDecimal(endIPStr)
+			w.to = n.String()
+		}
+
+		if pass == 0 {
+			switch {
+			case w.fromIPv4 && w.toIPv4:
+				w.no2From, _ = refGetIPv4First2Octet(w.from)
+				w.no2To, _ = refGetIPv4First2Octet(w.to)
+			case !w.fromIPv4 && !w.toIPv4:
+				w.no2From, _ = refGetIPv6First2Octet(w.from)
+				w.no2To, _ = refGetIPv6First2Octet(w.to)
+			case w.fromIPv4:
+				w.no2From, _ = refGetIPv4First2Octet(w.from)
+				w.no2To, _ = refGetIPv4First2Octet(refMaxIPv4Range.String())
+			}
+		} else {
+			var b []byte
+			if w.fromIPv4 {
+				b, _ = refIPv4ToBytes(startIPStr)
+			} else {
+				b, _ = refIPv6ToBytes(startIPStr)
+			}
+			refReverseBytes(b)
+			w.fromBytes = b
+		}
+	}
+	return w, true
+}
+
+// the edges of the IP numbers and the strings big.Int SetString takes or not
+var uint128Tests = []string{
+	"0",
+	"1",
+	"16777215",
+	"4294967295",                             // 255.255.255.255
+	"4294967296",                             // ::1:0:0
+	"281470681743359",                        // before ::ffff:0.0.0.0
+	"281470681743360",                        // ::ffff:0.0.0.0
+	"281474976710655",                        // ::ffff:255.255.255.255
+	"281474976710656",                        // after ::ffff:255.255.255.255
+	"18446744073709551615",                   // 2^64-1
+	"18446744073709551616",                   // 2^64
+	"42540528726795050063891204319802818560", // 2001:200::
+	"340282366920938463463374607431768211455", // 2^128-1
+	"340282366920938463463374607431768211456", // 2^128
+	"999999999999999999999999999999999999999",
+	"0000000000000000000000000000000000000000042",
+	"+42",
+	"-42",
+	"-0",
+	"+",
+	"-",
+	"",
+	" 42",
+	"42 ",
+	"\t42",
+	"4 2",
+	"4_2",
+	"0x2a",
+	"1e3",
+}
+
+func TestParseUint128(t *testing.T) {
+	for _, s := range uint128Tests {
+		want, wantOK := refParse(s)
+		got, ok := parseUint128(s)
+		if ok != wantOK {
+			t.Errorf("parseUint128(%q) ok = %v, want %v", s, ok, wantOK)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got.bigInt().Cmp(want) != 0 {
+			t.Errorf("parseUint128(%q) = %v, want %v", s, got.bigInt(), want)
+		}
+		if got.String() != want.String() {
+			t.Errorf("parseUint128(%q).String() = %q, want %q", s, got.String(), want.String())
+		}
+	}
+}
+
+func TestUint128IP(t *testing.T) {
+	for _, s := range uint128Tests {
+		n, ok := parseUint128(s)
+		if !ok {
+			continue
+		}
+
+		// IPv4 or IPv4-mapped IPv6 like net.IP formats them
+		want := rowIPWork{from: s}
+		if ref, ok := refRowIPWork(s, s); ok {
+			want = ref
+		}
+		v4, ok := n.ipv4()
+		if ok != want.fromIPv4 || (ok && strconv.FormatUint(uint64(v4), 10) != want.from) {
+			t.Errorf("%s.ipv4() = %d, %v, want %s, %v", s, v4, ok, want.from, want.fromIPv4)
+		}
+		if ok {
+			if got := ipv4LittleEndian(v4); !bytes.Equal(got, want.fromBytes) {
+				t.Errorf("ipv4LittleEndian(%d) = %x, want %x", v4, got, want.fromBytes)
+			}
+		}
+
+		no2, _ := refGetIPv6First2Octet(s)
+		if got := n.first2Octet(); got != no2 {
+			t.Errorf("%s.first2Octet() = %d, want %d", s, got, no2)
+		}
+
+		ip, _ := refForceAsIPv6(s)
+		refReverseBytes(ip)
+		if got := n.littleEndian(); !bytes.Equal(got, ip) {
+			t.Errorf("%s.littleEndian() = %x, want %x", s, got, ip)
+		}
+	}
+}
+
+// TestRowIPWork checks the IP number work of WriteBIN with the uint128 helpers against the big.Int helpers for
+// the ranges of the test CSV and the ranges between the edges
+func TestRowIPWork(t *testing.T) {
+	ranges := [][2]string{}
+	rdr := csv.NewReader(bytes.NewReader(readTestdata(t, "db5.csv")))
+	records, err := rdr.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, parts := range records {
+		ranges = append(ranges, [2]string{parts[0], parts[1]})
+	}
+	for _, from := range uint128Tests {
+		for _, to := range uint128Tests {
+			ranges = append(ranges, [2]string{from, to})
+		}
+	}
+
+	for _, r := range ranges {
+		want, wantOK := refRowIPWork(r[0], r[1])
+		got, ok := newRowIPWork(r[0], r[1])
+		if ok != wantOK {
+			t.Errorf("%s to %s: ok = %v, want %v", r[0], r[1], ok, wantOK)
+		} else if ok && (got.from != want.from || got.to != want.to || got.fromIPv4 != want.fromIPv4 || got.toIPv4 != want.toIPv4 ||
+			got.no2From != want.no2From || got.no2To != want.no2To || !bytes.Equal(got.fromBytes, want.fromBytes)) {
+			t.Errorf("%s to %s: got %+v, want %+v", r[0], r[1], got, want)
+		}
+	}
+}
+
+// IP numbers as found in the IPv6 CSV, from the IPv4 numbers at the start to the last IPv6 number
+var benchIPNumbers = []string{
+	"16777216",
+	"3758096383",
+	"281470698520576",
+	"42540766411282592856903984951653826560",
+	"58569071813452613185929873510317667327",
+	"340282366920938463463374607431768211455",
+}
+
+func BenchmarkParseUint128(b *testing.B) {
+	b.Run("uint128", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, ok := parseUint128(benchIPNumbers[i%len(benchIPNumbers)]); !ok {
+				b.Fatal("parse failed")
+			}
+		}
+	})
+	b.Run("big.Int", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, ok := new(big.Int).SetString(benchIPNumbers[i%len(benchIPNumbers)], 10); !ok {
+				b.Fatal("parse failed")
+			}
+		}
+	})
+}
+
+// BenchmarkWriteBINRow compares the IP number work WriteBIN does for every CSV row with the uint128 helpers and
+// with the big.Int helpers it used before
+func BenchmarkWriteBINRow(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		work func(from string, to string) (rowIPWork, bool)
+	}{
+		{"uint128", newRowIPWork},
+		{"big.Int", refRowIPWork},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, ok := bench.work(benchIPNumbers[i%len(benchIPNumbers)], benchIPNumbers[(i+1)%len(benchIPNumbers)]); !ok {
+					b.Fatal("bad IP number")
+				}
+			}
+		})
+	}
+}
+
+// benchDB1CSV returns an IPv6 DB1 CSV laid out like the IP2Location LITE one, with the rows split evenly between
+// the IPv4-mapped IPv6 range and the IPv6 numbers from 2001:200::
+func benchDB1CSV(rows int) []byte {
+	var buf bytes.Buffer
+	out := bufio.NewWriter(&buf)
+	record := func(start uint128, end uint128, fields ...string) uint128 {
+		WriteCSVRecord(out, append([]string{start.String(), end.String()}, fields...))
+		return end.addOne()
+	}
+
+	half := uint64(rows / 2)
+	lastIPv4 := ipv4MappedStartNumber.add(uint128{lo: 0xdfffffff}) // the LITE ends the IPv4 rows at 223.255.255.255
+	start := record(uint128{}, ipv4MappedStartNumber.subOne(), "-", "-")
+	step4 := 0xe0000000 / half
+	for i := uint64(1); i < half; i++ {
+		start = record(start, start.add(uint128{lo: step4 - 1}), "US", "United States of America")
+	}
+	start = record(start, lastIPv4, "US", "United States of America")
+	start = record(start, ipv4MappedEndNumber, "-", "-")
+
+	ipv6Start := uint128{hi: 0x2001<<48 | 0x200<<32}
+	start = record(start, ipv6Start.subOne(), "-", "-")
+	step6 := uint64(0x1000<<48) / half
+	for i := uint64(1); i < half; i++ {
+		start = record(start, start.add(uint128{hi: step6}).subOne(), "JP", "Japan")
+	}
+	record(start, maxIPv6Number, "-", "-")
+	out.Flush()
+	return buf.Bytes()
+}
+
+// BenchmarkWriteBIN converts a CSV of 100k rows. The IPWork benchmarks only do the IP number work of WriteBIN
+// for the rows of the same CSV, with the uint128 helpers and with the big.Int helpers WriteBIN used before, so
+// the time saved on the conversion is the difference between the two.
+func BenchmarkWriteBIN(b *testing.B) {
+	data := benchDB1CSV(100000)
+	b.Run("WriteBIN", func(b *testing.B) {
+		opts := BINOptions{Package: 1, IPFamily: "6", Date: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)}
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			if err := WriteBIN(bytes.NewReader(data), io.Discard, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("IPWork", func(b *testing.B) {
+		for _, bench := range []struct {
+			name string
+			work func(from string, to string) (rowIPWork, bool)
+		}{
+			{"uint128", newRowIPWork},
+			{"big.Int", refRowIPWork},
+		} {
+			b.Run(bench.name, func(b *testing.B) {
+				b.SetBytes(int64(len(data)))
+				for i := 0; i < b.N; i++ {
+					for _, parts := range records {
+						if _, ok := bench.work(parts[0], parts[1]); !ok {
+							b.Fatal("bad IP number")
+						}
+					}
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package convert
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strings"
+)
+
+func GetSortedKeys(myMap map[string]uint32) []string {
+	keys := make([]string, 0, len(myMap))
+
+	for k := range myMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func GetSortedKeysCountry(myMap map[string]*countryType) []string {
+	keys := make([]string, 0, len(myMap))
+
+	for k := range myMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func GetSortedKeysUint(myMap map[uint32]uint32) []uint32 {
+	keys := make([]uint32, 0, len(myMap))
+
+	for k := range myMap {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func concatSlice[T any](first []T, second []T) []T {
+	n := len(first)
+	return append(first[:n:n], second...)
+}
+
+func EqualFields(first []string, second []string) bool {
+	if len(first) != len(second) {
+		return false
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteCSVRecord writes the fields with every field quoted like the IP2Location CSV files
+func WriteCSVRecord(out *bufio.Writer, parts []string) {
+	for i, v := range parts {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		out.WriteByte('"')
+		out.WriteString(strings.ReplaceAll(v, `"`, `""`))
+		out.WriteByte('"')
+	}
+	out.WriteByte('\n')
+}
+
+func DashIfEmpty(str string) string {
+	if str == "" {
+		return "-"
+	}
+	return str
+}
+
+// SyncOutput flushes the output to disk if it is a file. Pipes and devices like stdout cannot be synced so they are
+// left alone.
+func SyncOutput(out any) error {
+	if f, ok := out.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && !info.Mode().IsRegular() {
+			return nil
+		}
+	}
+	if f, ok := out.(interface{ Sync() error }); ok {
+		return f.Sync()
+	}
+	return nil
+}
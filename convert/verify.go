@@ -0,0 +1,260 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var aliasedNetworks = []*net.IPNet{
+	{IP: net.ParseIP("2001::"), Mask: net.CIDRMask(32, 128)}, // Teredo
+	{IP: net.ParseIP("2002::"), Mask: net.CIDRMask(16, 128)}, // 6to4
+}
+
+// VerifyOptions are the options for VerifyBIN and VerifyMMDB
+type VerifyOptions struct {
+	Package uint8  // DB or PX package of the CSV for VerifyBIN, must match the BIN header
+	Type    string // "country" or "city" for VerifyMMDB
+	Sample  int    // check every sample-th range only, 1 to check all the ranges
+}
+
+// VerifyResult has the counts from VerifyBIN and VerifyMMDB
+type VerifyResult struct {
+	Ranges     int // CSV ranges
+	Checked    int // CSV ranges looked up
+	Missing    int // IP addresses not found
+	Mismatches int // fields that are different
+}
+
+// OK returns true if nothing is missing or different
+func (res VerifyResult) OK() bool {
+	return res.Missing == 0 && res.Mismatches == 0
+}
+
+// VerifyBIN looks up the start, middle and end IP addresses of every CSV range in the BIN and compares all the
+// fields of the package. Only every sample-th range is checked when sample is more than 1. Every missing or
+// different value is written to out.
+func VerifyBIN(in io.ReadSeeker, rdr *BINReader, opts VerifyOptions, out io.Writer) (VerifyResult, error) {
+	var res VerifyResult
+
+	productName := "IP2Location"
+	packageName := "DB"
+	if rdr.Header.ProductCode == 2 {
+		productName = "IP2Proxy"
+		packageName = "PX"
+	}
+
+	if opts.Package != rdr.Header.DBType {
+		return res, newClassError(ErrOption, "BIN file is %s%d not %s%d.", packageName, rdr.Header.DBType, packageName, opts.Package)
+	}
+	if ipFamily, err := DetectCSVIPFamily(in); err != nil {
+		return res, err
+	} else if ipFamily == "6" && rdr.Header.IPv6Count == 0 {
+		return res, newClassError(ErrIPFamily, "Please use %s IPv4 CSV.", productName)
+	}
+
+	names := rdr.ColumnNames()
+	floats := make([]bool, len(names))
+	i := 0
+	for _, col := range rdr.columns {
+		floats[i] = col.kind == columnFloat
+		if col.kind == columnCountry {
+			i++
+		}
+		i++
+	}
+
+	// same changes to the CSV as WriteBIN
+	prepare := func(parts []string) bool {
+		if rdr.Header.ProductCode == 2 {
+			return true
+		}
+		return rewriteLITERecord(parts)
+	}
+
+	err := WalkVerifyCSV(in, fmt.Sprintf("%s%d", packageName, rdr.Header.DBType), len(names)+2, opts.Sample, prepare, &res, out, func(line int, parts []string, ips []net.IP) error {
+		for _, ip := range ips {
+			isIPv6, row, found, err := rdr.FindRow(ip)
+			if err != nil {
+				return err
+			}
+			if !found {
+				fmt.Fprintf(out, "Line %d: %v not found in the BIN.\n", line, ip)
+				res.Missing++
+				continue
+			}
+
+			_, fields, err := rdr.ReadRow(isIPv6, row)
+			if err != nil {
+				return err
+			}
+			for i, name := range names {
+				expected := parts[i+2]
+				if floats[i] {
+					f, _ := strconv.ParseFloat(expected, 64)
+					expected = FormatCoordinate(float32(f)) // BIN only keeps float32
+				}
+				if fields[i] != expected {
+					fmt.Fprintf(out, "Line %d: %v %s is %q in the CSV but %q in the BIN.\n", line, ip, name, parts[i+2], fields[i])
+					res.Mismatches++
+				}
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// VerifyMMDB looks up the start, middle and end IP addresses of every DB1 or DB9 CSV range in the MMDB from
+// csv2mmdb and compares the country, subdivision, city, location and postal values. Only every sample-th range
+// is checked when sample is more than 1. Every missing or different value is written to out.
+func VerifyMMDB(in io.ReadSeeker, rdr *maxminddb.Reader, opts VerifyOptions, out io.Writer) (VerifyResult, error) {
+	var res VerifyResult
+
+	var paths []string
+	var dbName string
+	if opts.Type == "country" {
+		paths = []string{"country.iso_code", "country.names.en"}
+		dbName = "DB1"
+	} else if opts.Type == "city" {
+		paths = []string{"country.iso_code", "country.names.en", "subdivisions.0.names.en", "city.names.en", "location.latitude", "location.longitude", "postal.code"}
+		dbName = "DB9"
+	} else {
+		return res, newClassError(ErrOption, "Invalid MMDB type.")
+	}
+
+	err := WalkVerifyCSV(in, dbName, len(paths)+2, opts.Sample, nil, &res, out, func(line int, parts []string, ips []net.IP) error {
+		for _, ip := range ips {
+			if IsAliasedIP(ip) {
+				fmt.Fprintf(out, "Line %d: %v is in an aliased network so the range is not in the MMDB.\n", line, ip)
+				res.Missing++
+				continue
+			}
+
+			var record map[string]any
+			_, found, err := rdr.LookupNetwork(ip, &record)
+			if err != nil {
+				return &IOError{Op: "Unable to read MMDB file.", Err: err}
+			}
+			if !found {
+				fmt.Fprintf(out, "Line %d: %v not found in the MMDB.\n", line, ip)
+				res.Missing++
+				continue
+			}
+
+			for i, path := range paths {
+				isFloat := strings.HasPrefix(path, "location.l")
+				expected := parts[i+2]
+				if isFloat {
+					f, _ := strconv.ParseFloat(expected, 64)
+					expected = strconv.FormatFloat(f, 'f', 6, 64)
+				}
+				if value := MMDBPathValue(record, []string{path}, isFloat); value != expected {
+					fmt.Fprintf(out, "Line %d: %v %s is %q in the CSV but %q in the MMDB.\n", line, ip, path, parts[i+2], value)
+					res.Mismatches++
+				}
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// WalkVerifyCSV calls check with the line number, the record and the IP addresses to look up for every sample-th
+// CSV range. Records are skipped when prepare returns false. The range counts are added to res and invalid ranges
+// are written to out and counted as mismatches.
+func WalkVerifyCSV(in io.ReadSeeker, dbName string, columns int, sample int, prepare func(parts []string) bool, res *VerifyResult, out io.Writer, check func(line int, parts []string, ips []net.IP) error) error {
+	var err error
+
+	if sample < 1 {
+		return newClassError(ErrOption, "Invalid sample rate.")
+	}
+
+	var ipFamily string
+	if ipFamily, err = DetectCSVIPFamily(in); err != nil {
+		return err
+	}
+
+	csvRdr := csv.NewReader(bufio.NewReaderSize(in, 65536))
+	csvRdr.Comma = ','
+	csvRdr.LazyQuotes = false
+	csvRdr.FieldsPerRecord = -1 // column count is checked below
+
+	for {
+		parts, err := csvRdr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return csvReadError(err)
+		}
+		line, _ := csvRdr.FieldPos(0)
+
+		if len(parts) != columns {
+			return newClassError(ErrColumnCount, "Line %d: %s CSV should have %d columns.", line, dbName, columns)
+		}
+		if prepare != nil && !prepare(parts) {
+			continue
+		}
+
+		res.Ranges++
+		if (res.Ranges-1)%sample != 0 {
+			continue
+		}
+		res.Checked++
+
+		start, ok1 := parseUint128(parts[0])
+		end, ok2 := parseUint128(parts[1])
+		if !ok1 || !ok2 || start.cmp(end) > 0 {
+			fmt.Fprintf(out, "Line %d: Invalid IP range.\n", line)
+			res.Mismatches++
+			continue
+		}
+
+		if err = check(line, parts, VerifyPoints(start, end, ipFamily)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsAliasedIP returns true for the 6to4 and Teredo networks which the MMDB aliases to the IPv4 networks
+func IsAliasedIP(ip net.IP) bool {
+	for _, network := range aliasedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyPoints returns the start, middle and end IP addresses of the CSV range to look up. IPv4-mapped IPv6 is
+// returned as IPv4 since that is where the BIN and MMDB keep them. IP numbers below 4294967296 in the IPv6 CSV are
+// also converted into IPv4 by csv2bin and csv2mmdb so they cannot be looked up and are skipped.
+func VerifyPoints(start uint128, end uint128, ipFamily string) []net.IP {
+	half := end.sub(start)
+	half = uint128{hi: half.hi >> 1, lo: half.lo>>1 | half.hi<<63}
+	mid := start.add(half)
+
+	ips := []net.IP{}
+	for i, n := range []uint128{start, mid, end} {
+		if i > 0 && n == start || i > 1 && n == mid {
+			continue // range too small to have 3 different IP addresses
+		}
+
+		if ipFamily == "4" {
+			if n.cmp(maxIPv4Number) <= 0 {
+				ips = append(ips, n.ip())
+			}
+		} else if n.ipv4Mapped() {
+			ips = append(ips, n.sub(ipv4MappedStartNumber).ip())
+		} else if n.cmp(maxIPv4Number) > 0 {
+			ips = append(ips, n.ipv6())
+		}
+	}
+	return ips
+}
@@ -2,10 +2,14 @@ module github.com/ip2location/ip2convert
 
 go 1.18
 
-require github.com/maxmind/mmdbwriter v1.0.0
+require (
+	github.com/klauspost/compress v1.17.2
+	github.com/maxmind/mmdbwriter v1.0.0
+	github.com/oschwald/maxminddb-golang v1.12.0
+	github.com/ulikunitz/xz v0.5.15
+)
 
 require (
-	github.com/oschwald/maxminddb-golang v1.12.0 // indirect
 	go4.org/netipx v0.0.0-20220812043211-3cc044ffd68d // indirect
 	golang.org/x/sys v0.10.0 // indirect
 )
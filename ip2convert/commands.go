@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/ip2location/ip2convert/convert"
+	"github.com/oschwald/maxminddb-golang"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exit codes for the error classes so that scripts can tell the failures apart
+const (
+	exitFailure       = 1 // any other error
+	exitUsage         = 2 // invalid command line, same as the flag package
+	exitIO            = 3
+	exitColumnCount   = 4
+	exitIPNumber      = 5
+	exitNotContiguous = 6
+	exitInvalidData   = 7
+	exitIPFamily      = 8
+	exitMismatch      = 9 // verify found mismatches
+)
+
+// exitCode returns the exit code for the class of the error
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, convert.ErrIO):
+		return exitIO
+	case errors.Is(err, convert.ErrColumnCount):
+		return exitColumnCount
+	case errors.Is(err, convert.ErrIPNumber):
+		return exitIPNumber
+	case errors.Is(err, convert.ErrNotContiguous):
+		return exitNotContiguous
+	case errors.Is(err, convert.ErrInvalidData):
+		return exitInvalidData
+	case errors.Is(err, convert.ErrIPFamily):
+		return exitIPFamily
+	case errors.Is(err, convert.ErrOption):
+		return exitUsage
+	}
+	return exitFailure
+}
+
+// fatal prints the error to stderr and exits with the code for the error class
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err.Error())
+	os.Exit(exitCode(err))
+}
+
+// usageError prints the message to stderr and exits with the usage exit code
+func usageError(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(exitUsage)
+}
+
+// parsePackage returns the DB or PX package number already checked by the regex, 0 if empty
+func parsePackage(dbPackage string) uint8 {
+	dbType, _ := strconv.ParseUint(dbPackage, 10, 8)
+	return uint8(dbType)
+}
+
+// stdioPath is the input path for stdin and the output path for stdout
+const stdioPath = "-"
+
+// convertFile opens the input file and writes the output file for the conversion. The output is written to a temp
+// file and only replaces the output file after it passes check, check is skipped if nil. The "-" input is stdin
+// and the "-" output is stdout, which is written directly without the check.
+func convertFile(input string, output string, check func(path string) error, convertFn func(in *os.File, out *os.File) error) error {
+	var err error
+	inFile := os.Stdin
+	if input != stdioPath {
+		if inFile, err = os.Open(input); err != nil {
+			return &convert.IOError{Op: fmt.Sprintf("Invalid input file %v.", input), Err: err}
+		}
+		defer inFile.Close()
+	}
+
+	if output == stdioPath {
+		fmt.Fprintln(os.Stderr, "Writing to stdout")
+		return convertFn(inFile, os.Stdout)
+	}
+	fmt.Fprintf(os.Stderr, "Writing to %s\n", output)
+	return convert.WriteFileAtomic(output, func(out *os.File) error {
+		return convertFn(inFile, out)
+	}, check)
+}
+
+// convertCSVFile opens the input CSV file, decompressed if it is compressed or a ZIP archive, and writes the output
+// file for the conversion. entry is the CSV file in the ZIP archive. The CSV from stdin is read as it comes in.
+// date is the date of the database, the default from csvDate if zero.
+func convertCSVFile(input string, entry string, date time.Time, output string, check func(path string) error, convertFn func(in io.Reader, date time.Time, out *os.File) error) error {
+	return convertFile(input, output, check, func(in *os.File, out *os.File) error {
+		csvIn, err := convert.DecompressCSV(in, entry)
+		if err != nil {
+			return err
+		}
+		defer csvIn.Close()
+		if date.IsZero() {
+			date = csvDate(input, in, csvIn)
+		}
+		fmt.Fprintf(os.Stderr, "Database date %s\n", date.Format(dateLayout))
+		return convertFn(csvIn, date, out)
+	})
+}
+
+// csvDate returns the default date of the database for the CSV. The date in the name of the ZIP entry or of the
+// input file comes first, then the modified time of the ZIP entry or of the input file, then today.
+func csvDate(input string, in *os.File, csvIn io.Reader) time.Time {
+	usable := func(date time.Time) bool {
+		return date.Year() >= 2000 && date.Year() <= 2099 // the BIN only has the last 2 digits of the year
+	}
+	zipEntry := convert.ZIPEntry(csvIn)
+	if zipEntry != nil {
+		if date, ok := convert.DateFromName(zipEntry.Name); ok {
+			return date
+		}
+	}
+	if input != stdioPath {
+		if date, ok := convert.DateFromName(input); ok {
+			return date
+		}
+	}
+	if zipEntry != nil && usable(zipEntry.Modified) {
+		return zipEntry.Modified
+	}
+	if info, err := in.Stat(); err == nil && info.Mode().IsRegular() && usable(info.ModTime()) {
+		return info.ModTime()
+	}
+	return time.Now()
+}
+
+// dateLayout is the layout of the -date option
+const dateLayout = "2006-01-02"
+
+// checkDate checks the -date option and returns the date, zero if not specified
+func checkDate(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	date, err := time.Parse(dateLayout, value)
+	if err != nil || date.Year() < 2000 || date.Year() > 2099 {
+		usageError("Invalid date.")
+	}
+	return date
+}
+
+// convertBINFile opens the input BIN file and writes the output file for the conversion. The BIN from stdin is
+// copied to a temp file first unless stdin is a file since the rows are read out of order.
+func convertBINFile(input string, output string, check func(path string) error, convertFn func(rdr *convert.BINReader, out *os.File) error) error {
+	return convertFile(input, output, check, func(in *os.File, out *os.File) error {
+		if input == stdioPath {
+			file, remove, err := seekableStdin()
+			if err != nil {
+				return err
+			}
+			defer remove()
+			in = file
+		}
+		rdr, err := convert.NewBINReader(in)
+		if err != nil {
+			return err
+		}
+		return convertFn(rdr, out)
+	})
+}
+
+// convertMMDBFile opens the input MMDB file and writes the output file for the conversion. The MMDB from stdin is
+// read into memory.
+func convertMMDBFile(input string, output string, check func(path string) error, convertFn func(rdr *maxminddb.Reader, out *os.File) error) error {
+	var rdr *maxminddb.Reader
+	var err error
+	if input == stdioPath {
+		var data []byte
+		if data, err = io.ReadAll(os.Stdin); err != nil {
+			return &convert.IOError{Op: "Unable to read input file.", Err: err}
+		}
+		rdr, err = maxminddb.FromBytes(data)
+	} else {
+		rdr, err = maxminddb.Open(input)
+	}
+	if err != nil {
+		return &convert.IOError{Op: fmt.Sprintf("Invalid input file %v.", input), Err: err}
+	}
+	defer rdr.Close()
+
+	return convertFile(input, output, check, func(in *os.File, out *os.File) error {
+		return convertFn(rdr, out)
+	})
+}
+
+// seekableStdin returns stdin if it is a file, otherwise a temp file with a copy of stdin which remove deletes
+func seekableStdin() (file *os.File, remove func(), err error) {
+	if _, err = os.Stdin.Seek(0, io.SeekCurrent); err == nil {
+		return os.Stdin, func() {}, nil
+	}
+	if file, err = os.CreateTemp("", "ip2convert-*.tmp"); err != nil {
+		return nil, nil, &convert.IOError{Op: "Unable to create temp file.", Err: err}
+	}
+	remove = func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	if _, err = io.Copy(file, os.Stdin); err != nil {
+		remove()
+		return nil, nil, &convert.IOError{Op: "Unable to read input file.", Err: err}
+	}
+	return file, remove, nil
+}
+
+// withOnError calls convertFn with the OnError option for the on-error mode, nil for abort. The bad records are
+// written to the quarantine file for quarantine, which is only put in place when the conversion succeeds. The number
+// of records left out is printed after the conversion.
+func withOnError(mode string, quarantinePath string, convertFn func(onError convert.RecordErrorFunc) error) error {
+	if mode == "abort" {
+		return convertFn(nil)
+	}
+
+	count := 0
+	counted := func(onError convert.RecordErrorFunc) convert.RecordErrorFunc {
+		return func(err *convert.ParseError) error {
+			count++
+			return onError(err)
+		}
+	}
+	if mode == "skip" {
+		err := convertFn(counted(convert.SkipRecords))
+		fmt.Fprintf(os.Stderr, "Skipped %d bad records.\n", count)
+		return err
+	}
+
+	err := convert.WriteFileAtomic(quarantinePath, func(out *os.File) error {
+		return convertFn(counted(convert.QuarantineRecords(out)))
+	}, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Quarantined %d bad records to %s\n", count, quarantinePath)
+	return nil
+}
+
+// checkOnError checks the on-error mode and returns the quarantine file, next to the output file by default
+func checkOnError(mode string, quarantinePath string, output string) string {
+	if mode != "abort" && mode != "skip" && mode != "quarantine" {
+		usageError("Invalid on-error mode.")
+	}
+	if quarantinePath == "" {
+		if mode == "quarantine" && output == stdioPath {
+			usageError("Quarantine file not specified.")
+		}
+		return output + ".quarantine.csv"
+	}
+	return quarantinePath
+}
+
+// checkJobs checks the number of goroutines parsing the CSV, 0 for the number of CPUs
+func checkJobs(jobs int) int {
+	if jobs < 0 {
+		usageError("Invalid number of jobs.")
+	}
+	if jobs == 0 {
+		return runtime.NumCPU()
+	}
+	return jobs
+}
+
+// PrintFileInfo prints the BIN header or the MMDB metadata
+func PrintFileInfo(input string, format string) error {
+	if format != "text" && format != "json" {
+		usageError("Invalid output format.")
+	}
+
+	bin, mmdb, err := convert.OpenDatabase(input)
+	if err != nil {
+		return err
+	}
+
+	var fields []convert.InfoField
+	if mmdb != nil {
+		fields = convert.MMDBInfo(mmdb, input)
+		mmdb.Close()
+	} else {
+		fields = convert.BINInfo(bin, input)
+		bin.Close()
+	}
+
+	if format == "json" {
+		convert.PrintInfoJSON(os.Stdout, fields)
+	} else {
+		convert.PrintInfoText(os.Stdout, fields)
+	}
+	return nil
+}
+
+// LookupIPs prints the fields found for each IP address in the BIN or MMDB file. The IP addresses are read
+// from the reader, one per line, when none are given.
+func LookupIPs(input string, format string, ips []string, in io.Reader) error {
+	if format != "table" && format != "json" {
+		usageError("Invalid output format.")
+	}
+
+	bin, mmdb, err := convert.OpenDatabase(input)
+	if err != nil {
+		return err
+	}
+
+	var lookup func(ip string) []convert.InfoField
+	if mmdb != nil {
+		defer mmdb.Close()
+		lookup = func(ip string) []convert.InfoField {
+			return convert.LookupMMDB(mmdb, ip)
+		}
+	} else {
+		defer bin.Close()
+		lookup = func(ip string) []convert.InfoField {
+			return convert.LookupBIN(bin, ip)
+		}
+	}
+
+	printResult := func(ip string) {
+		fields := lookup(ip)
+		if format == "json" {
+			convert.PrintInfoJSON(os.Stdout, fields) // one line per IP address
+		} else {
+			convert.PrintInfoText(os.Stdout, fields)
+			fmt.Println()
+		}
+	}
+
+	if len(ips) > 0 {
+		for _, ip := range ips {
+			printResult(ip)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		ip := strings.TrimSpace(scanner.Text())
+		if ip == "" || strings.HasPrefix(ip, "#") {
+			continue
+		}
+		printResult(ip)
+	}
+	if err = scanner.Err(); err != nil {
+		return &convert.IOError{Op: "Unable to read IP addresses.", Err: err}
+	}
+	return nil
+}
+
+// VerifyFile checks the BIN or MMDB file against the CSV and prints the counts
+func VerifyFile(input string, binFile string, mmdbFile string, opts convert.VerifyOptions) (convert.VerifyResult, error) {
+	var err error
+	var res convert.VerifyResult
+	var inFile *os.File
+	if inFile, err = os.Open(input); err != nil {
+		return res, &convert.IOError{Op: fmt.Sprintf("Invalid input file %v.", input), Err: err}
+	}
+	defer inFile.Close()
+
+	if binFile != "" {
+		var rdr *convert.BINReader
+		if rdr, err = convert.OpenBIN(binFile); err != nil {
+			return res, &convert.IOError{Op: fmt.Sprintf("Invalid BIN file %v.", binFile), Err: err}
+		}
+		defer rdr.Close()
+
+		if res, err = convert.VerifyBIN(inFile, rdr, opts, os.Stdout); err != nil {
+			return res, err
+		}
+		fmt.Printf("Checked %d of %d ranges, %d mismatches found.\n", res.Checked, res.Ranges, res.Missing+res.Mismatches)
+		return res, nil
+	}
+
+	var rdr *maxminddb.Reader
+	if rdr, err = maxminddb.Open(mmdbFile); err != nil {
+		return res, &convert.IOError{Op: fmt.Sprintf("Invalid MMDB file %v.", mmdbFile), Err: err}
+	}
+	defer rdr.Close()
+
+	if res, err = convert.VerifyMMDB(inFile, rdr, opts, os.Stdout); err != nil {
+		return res, err
+	}
+	fmt.Printf("Checked %d of %d ranges, %d missing and %d different found.\n", res.Checked, res.Ranges, res.Missing, res.Mismatches)
+	return res, nil
+}
+
+// DiffFiles prints the changes between the old and new BIN or MMDB files
+func DiffFiles(oldFile string, newFile string, format string) error {
+	if format != "text" && format != "json" {
+		usageError("Invalid output format.")
+	}
+
+	var sources []convert.DiffSource
+	for _, input := range []string{oldFile, newFile} {
+		bin, mmdb, err := convert.OpenDatabase(input)
+		if err != nil {
+			return err
+		}
+		if mmdb != nil {
+			defer mmdb.Close()
+			sources = append(sources, convert.MMDBDiffSource(mmdb))
+		} else {
+			defer bin.Close()
+			sources = append(sources, convert.BINDiffSource(bin))
+		}
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	err := convert.Diff(sources[0], sources[1], format, out)
+	if flushErr := out.Flush(); err == nil && flushErr != nil {
+		err = &convert.IOError{Op: "Writing to output failed.", Err: flushErr}
+	}
+	return err
+}
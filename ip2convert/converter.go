@@ -3,45 +3,138 @@ package main
 import (
 	"flag"
 	"fmt"
-	"math/big"
+	"github.com/ip2location/ip2convert/convert"
+	"github.com/oschwald/maxminddb-golang"
+	"io"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 )
 
 var cmdCSV2MMDBInput string
 var cmdCSV2MMDBOutput string
 var cmdCSV2MMDBType string
+var cmdCSV2MMDBDBPackage string
+var cmdCSV2MMDBOnError string
+var cmdCSV2MMDBQuarantine string
+var cmdCSV2MMDBJobs int
+var cmdCSV2MMDBEntry string
+var cmdCSV2MMDBDate string
 var cmdCSV2BINDBPackage string
 
 var cmdCSV2BINInput string
 var cmdCSV2BINOutput string
+var cmdCSV2BINIPFamily string
+var cmdCSV2BINProduct string
+var cmdCSV2BINOnError string
+var cmdCSV2BINQuarantine string
+var cmdCSV2BINJobs int
+var cmdCSV2BINEntry string
+var cmdCSV2BINDate string
+
+var cmdBIN2CSVInput string
+var cmdBIN2CSVOutput string
+
+var cmdMMDB2CSVInput string
+var cmdMMDB2CSVOutput string
+var cmdMMDB2CSVType string
+
+var cmdBIN2MMDBInput string
+var cmdBIN2MMDBOutput string
+var cmdBIN2MMDBType string
+
+var cmdMMDB2BINDBPackage string
+var cmdMMDB2BINInput string
+var cmdMMDB2BINOutput string
+var cmdMMDB2BINMapping string
+
+var cmdInfoInput string
+var cmdInfoFormat string
+
+var cmdLookupInput string
+var cmdLookupFormat string
+
+var cmdVerifyInput string
+var cmdVerifyBIN string
+var cmdVerifyDBPackage string
+var cmdVerifyMMDB string
+var cmdVerifyType string
+var cmdVerifySample int
+
+var cmdDiffOld string
+var cmdDiffNew string
+var cmdDiffFormat string
 
 const version string = "1.2.2"
 const programName string = "ip2convert Geolocation File Format Converter"
 
 var showVer bool = false
-var maxIPv4Range *big.Int
-var maxIPv4RangePlusOne *big.Int
-var maxIPv6Range *big.Int
-
-func init() {
-	maxIPv4Range = big.NewInt(4294967295)
-	maxIPv4RangePlusOne = big.NewInt(4294967296)
-	maxIPv6Range = big.NewInt(0)
-	maxIPv6Range.SetString("340282366920938463463374607431768211455", 10)
-}
 
 func main() {
 	cmdCSV2MMDB := flag.NewFlagSet("csv2mmdb", flag.ExitOnError)
 	cmdCSV2MMDB.StringVar(&cmdCSV2MMDBInput, "i", "", "Input CSV file")
 	cmdCSV2MMDB.StringVar(&cmdCSV2MMDBOutput, "o", "", "Output MMDB file")
 	cmdCSV2MMDB.StringVar(&cmdCSV2MMDBType, "t", "", "MMDB file type")
+	cmdCSV2MMDB.StringVar(&cmdCSV2MMDBDBPackage, "d", "", "DB package")
+	cmdCSV2MMDB.StringVar(&cmdCSV2MMDBOnError, "on-error", "abort", "What to do with bad CSV records")
+	cmdCSV2MMDB.StringVar(&cmdCSV2MMDBQuarantine, "quarantine", "", "File for the quarantined CSV records")
+	cmdCSV2MMDB.IntVar(&cmdCSV2MMDBJobs, "j", 1, "Number of goroutines parsing the CSV")
+	cmdCSV2MMDB.StringVar(&cmdCSV2MMDBEntry, "entry", "", "CSV file in the ZIP archive")
+	cmdCSV2MMDB.StringVar(&cmdCSV2MMDBDate, "date", "", "Date of the database (YYYY-MM-DD)")
 
 	cmdCSV2BIN := flag.NewFlagSet("csv2bin", flag.ExitOnError)
 	cmdCSV2BIN.StringVar(&cmdCSV2BINDBPackage, "d", "", "DB package")
 	cmdCSV2BIN.StringVar(&cmdCSV2BINInput, "i", "", "Input CSV file")
 	cmdCSV2BIN.StringVar(&cmdCSV2BINOutput, "o", "", "Output BIN file")
+	cmdCSV2BIN.StringVar(&cmdCSV2BINIPFamily, "f", "", "IP family of the CSV file")
+	cmdCSV2BIN.StringVar(&cmdCSV2BINProduct, "p", "db", "Product of the CSV file")
+	cmdCSV2BIN.StringVar(&cmdCSV2BINOnError, "on-error", "abort", "What to do with bad CSV records")
+	cmdCSV2BIN.StringVar(&cmdCSV2BINQuarantine, "quarantine", "", "File for the quarantined CSV records")
+	cmdCSV2BIN.IntVar(&cmdCSV2BINJobs, "j", 1, "Number of goroutines parsing the CSV")
+	cmdCSV2BIN.StringVar(&cmdCSV2BINEntry, "entry", "", "CSV file in the ZIP archive")
+	cmdCSV2BIN.StringVar(&cmdCSV2BINDate, "date", "", "Date of the database (YYYY-MM-DD)")
+
+	cmdBIN2CSV := flag.NewFlagSet("bin2csv", flag.ExitOnError)
+	cmdBIN2CSV.StringVar(&cmdBIN2CSVInput, "i", "", "Input BIN file")
+	cmdBIN2CSV.StringVar(&cmdBIN2CSVOutput, "o", "", "Output CSV file")
+
+	cmdMMDB2CSV := flag.NewFlagSet("mmdb2csv", flag.ExitOnError)
+	cmdMMDB2CSV.StringVar(&cmdMMDB2CSVInput, "i", "", "Input MMDB file")
+	cmdMMDB2CSV.StringVar(&cmdMMDB2CSVOutput, "o", "", "Output CSV file")
+	cmdMMDB2CSV.StringVar(&cmdMMDB2CSVType, "t", "", "CSV file type")
+
+	cmdBIN2MMDB := flag.NewFlagSet("bin2mmdb", flag.ExitOnError)
+	cmdBIN2MMDB.StringVar(&cmdBIN2MMDBInput, "i", "", "Input BIN file")
+	cmdBIN2MMDB.StringVar(&cmdBIN2MMDBOutput, "o", "", "Output MMDB file")
+	cmdBIN2MMDB.StringVar(&cmdBIN2MMDBType, "t", "", "MMDB file type")
+
+	cmdMMDB2BIN := flag.NewFlagSet("mmdb2bin", flag.ExitOnError)
+	cmdMMDB2BIN.StringVar(&cmdMMDB2BINDBPackage, "d", "", "DB package")
+	cmdMMDB2BIN.StringVar(&cmdMMDB2BINInput, "i", "", "Input MMDB file")
+	cmdMMDB2BIN.StringVar(&cmdMMDB2BINOutput, "o", "", "Output BIN file")
+	cmdMMDB2BIN.StringVar(&cmdMMDB2BINMapping, "m", "", "Mapping of BIN columns to MMDB paths")
+
+	cmdInfo := flag.NewFlagSet("info", flag.ExitOnError)
+	cmdInfo.StringVar(&cmdInfoInput, "i", "", "Input BIN or MMDB file")
+	cmdInfo.StringVar(&cmdInfoFormat, "f", "text", "Output format")
+
+	cmdLookup := flag.NewFlagSet("lookup", flag.ExitOnError)
+	cmdLookup.StringVar(&cmdLookupInput, "i", "", "Input BIN or MMDB file")
+	cmdLookup.StringVar(&cmdLookupFormat, "f", "table", "Output format")
+
+	cmdVerify := flag.NewFlagSet("verify", flag.ExitOnError)
+	cmdVerify.StringVar(&cmdVerifyInput, "i", "", "Input CSV file")
+	cmdVerify.StringVar(&cmdVerifyBIN, "b", "", "BIN file to verify")
+	cmdVerify.StringVar(&cmdVerifyDBPackage, "d", "", "DB or PX package number")
+	cmdVerify.StringVar(&cmdVerifyMMDB, "m", "", "MMDB file to verify")
+	cmdVerify.StringVar(&cmdVerifyType, "t", "", "MMDB type")
+	cmdVerify.IntVar(&cmdVerifySample, "s", 1, "Check 1 in every N ranges")
+
+	cmdDiff := flag.NewFlagSet("diff", flag.ExitOnError)
+	cmdDiff.StringVar(&cmdDiffOld, "a", "", "Old BIN or MMDB file")
+	cmdDiff.StringVar(&cmdDiffNew, "b", "", "New BIN or MMDB file")
+	cmdDiff.StringVar(&cmdDiffFormat, "f", "text", "Output format")
 
 	flag.BoolVar(&showVer, "v", false, "Show version")
 
@@ -59,45 +152,212 @@ func main() {
 		return
 	}
 
+	var err error
 	switch os.Args[1] {
 	case "csv2mmdb":
 		cmdCSV2MMDB.Parse(os.Args[2:])
 		cmdCSV2MMDBInput = strings.TrimSpace(cmdCSV2MMDBInput)
 		cmdCSV2MMDBOutput = strings.TrimSpace(cmdCSV2MMDBOutput)
 		cmdCSV2MMDBType = strings.TrimSpace(cmdCSV2MMDBType)
+		cmdCSV2MMDBDBPackage = strings.TrimSpace(cmdCSV2MMDBDBPackage)
+		cmdCSV2MMDBOnError = strings.ToLower(strings.TrimSpace(cmdCSV2MMDBOnError))
+		cmdCSV2MMDBQuarantine = strings.TrimSpace(cmdCSV2MMDBQuarantine)
+		cmdCSV2MMDBEntry = strings.TrimSpace(cmdCSV2MMDBEntry)
+		cmdCSV2MMDBDate = strings.TrimSpace(cmdCSV2MMDBDate)
+		regexDBPackage := regexp.MustCompile(`^(([1-9])|(1[0-9])|(2[0-6]))$`) // 1 to 26 for the DB packages
+
 		if cmdCSV2MMDBInput == "" {
-			fmt.Println("Input file not specified.")
-			return
+			usageError("Input file not specified.")
 		}
 		if cmdCSV2MMDBOutput == "" {
-			fmt.Println("Output file not specified.")
-			return
+			usageError("Output file not specified.")
 		}
-		if cmdCSV2MMDBType == "" {
-			fmt.Println("MMDB type not specified.")
-			return
+		if cmdCSV2MMDBType != "" && cmdCSV2MMDBDBPackage != "" {
+			usageError("Please specify either MMDB type or DB package.")
 		}
-		ConvertCSV2MMDB(cmdCSV2MMDBInput, cmdCSV2MMDBOutput, cmdCSV2MMDBType)
+		if cmdCSV2MMDBDBPackage != "" && !regexDBPackage.MatchString(cmdCSV2MMDBDBPackage) {
+			usageError("Invalid DB package.")
+		}
+		if cmdCSV2MMDBType == "" && cmdCSV2MMDBDBPackage == "" {
+			usageError("MMDB type not specified.")
+		}
+		cmdCSV2MMDBQuarantine = checkOnError(cmdCSV2MMDBOnError, cmdCSV2MMDBQuarantine, cmdCSV2MMDBOutput)
+		date := checkDate(cmdCSV2MMDBDate)
+		opts := convert.MMDBOptions{Type: cmdCSV2MMDBType, Package: parsePackage(cmdCSV2MMDBDBPackage), Jobs: checkJobs(cmdCSV2MMDBJobs)}
+		err = withOnError(cmdCSV2MMDBOnError, cmdCSV2MMDBQuarantine, func(onError convert.RecordErrorFunc) error {
+			opts.OnError = onError
+			return convertCSVFile(cmdCSV2MMDBInput, cmdCSV2MMDBEntry, date, cmdCSV2MMDBOutput, convert.CheckMMDBFile, func(in io.Reader, date time.Time, out *os.File) error {
+				opts.Date = date
+				return convert.ConvertCSV2MMDB(in, out, opts)
+			})
+		})
 	case "csv2bin":
 		cmdCSV2BIN.Parse(os.Args[2:])
 		cmdCSV2BINDBPackage = strings.TrimSpace(cmdCSV2BINDBPackage)
 		cmdCSV2BINInput = strings.TrimSpace(cmdCSV2BINInput)
 		cmdCSV2BINOutput = strings.TrimSpace(cmdCSV2BINOutput)
+		cmdCSV2BINIPFamily = strings.TrimSpace(cmdCSV2BINIPFamily)
+		cmdCSV2BINProduct = strings.ToLower(strings.TrimSpace(cmdCSV2BINProduct))
+		cmdCSV2BINOnError = strings.ToLower(strings.TrimSpace(cmdCSV2BINOnError))
+		cmdCSV2BINQuarantine = strings.TrimSpace(cmdCSV2BINQuarantine)
+		cmdCSV2BINEntry = strings.TrimSpace(cmdCSV2BINEntry)
+		cmdCSV2BINDate = strings.TrimSpace(cmdCSV2BINDate)
 		regexDBPackage := regexp.MustCompile(`^(([1-9])|(1[0-9])|(2[0-6]))$`) // 1 to 26 for the DB packages
+		regexPXPackage := regexp.MustCompile(`^(([1-9])|(1[0-2]))$`)          // 1 to 12 for the PX packages
 
-		if !regexDBPackage.MatchString(cmdCSV2BINDBPackage) {
-			fmt.Println("DB package not specified.")
-			return
+		if cmdCSV2BINProduct != "db" && cmdCSV2BINProduct != "px" {
+			usageError("Invalid product.")
+		}
+		if cmdCSV2BINProduct == "px" && !regexPXPackage.MatchString(cmdCSV2BINDBPackage) {
+			usageError("PX package not specified.")
+		}
+		if cmdCSV2BINProduct == "db" && !regexDBPackage.MatchString(cmdCSV2BINDBPackage) {
+			usageError("DB package not specified.")
 		}
 		if cmdCSV2BINInput == "" {
-			fmt.Println("Input file not specified.")
-			return
+			usageError("Input file not specified.")
 		}
 		if cmdCSV2BINOutput == "" {
-			fmt.Println("Output file not specified.")
-			return
+			usageError("Output file not specified.")
+		}
+		if cmdCSV2BINIPFamily != "" && cmdCSV2BINIPFamily != "4" && cmdCSV2BINIPFamily != "6" {
+			usageError("Invalid IP family.")
+		}
+		cmdCSV2BINQuarantine = checkOnError(cmdCSV2BINOnError, cmdCSV2BINQuarantine, cmdCSV2BINOutput)
+		date := checkDate(cmdCSV2BINDate)
+		opts := convert.BINOptions{Package: parsePackage(cmdCSV2BINDBPackage), IPFamily: cmdCSV2BINIPFamily, Jobs: checkJobs(cmdCSV2BINJobs)}
+		err = withOnError(cmdCSV2BINOnError, cmdCSV2BINQuarantine, func(onError convert.RecordErrorFunc) error {
+			opts.OnError = onError
+			return convertCSVFile(cmdCSV2BINInput, cmdCSV2BINEntry, date, cmdCSV2BINOutput, convert.CheckBINFile, func(in io.Reader, date time.Time, out *os.File) error {
+				opts.Date = date
+				if cmdCSV2BINProduct == "px" {
+					return convert.WriteProxyBIN(in, out, opts)
+				}
+				return convert.WriteBIN(in, out, opts)
+			})
+		})
+	case "bin2csv":
+		cmdBIN2CSV.Parse(os.Args[2:])
+		cmdBIN2CSVInput = strings.TrimSpace(cmdBIN2CSVInput)
+		cmdBIN2CSVOutput = strings.TrimSpace(cmdBIN2CSVOutput)
+		if cmdBIN2CSVInput == "" {
+			usageError("Input file not specified.")
+		}
+		if cmdBIN2CSVOutput == "" {
+			usageError("Output file not specified.")
+		}
+		err = convertBINFile(cmdBIN2CSVInput, cmdBIN2CSVOutput, nil, func(rdr *convert.BINReader, out *os.File) error {
+			return convert.ConvertBIN2CSV(rdr, out)
+		})
+	case "mmdb2csv":
+		cmdMMDB2CSV.Parse(os.Args[2:])
+		cmdMMDB2CSVInput = strings.TrimSpace(cmdMMDB2CSVInput)
+		cmdMMDB2CSVOutput = strings.TrimSpace(cmdMMDB2CSVOutput)
+		cmdMMDB2CSVType = strings.TrimSpace(cmdMMDB2CSVType)
+		if cmdMMDB2CSVInput == "" {
+			usageError("Input file not specified.")
+		}
+		if cmdMMDB2CSVOutput == "" {
+			usageError("Output file not specified.")
+		}
+		if cmdMMDB2CSVType == "" {
+			usageError("CSV type not specified.")
+		}
+		opts := convert.MMDBOptions{Type: cmdMMDB2CSVType}
+		err = convertMMDBFile(cmdMMDB2CSVInput, cmdMMDB2CSVOutput, nil, func(rdr *maxminddb.Reader, out *os.File) error {
+			return convert.ConvertMMDB2CSV(rdr, out, opts)
+		})
+	case "bin2mmdb":
+		cmdBIN2MMDB.Parse(os.Args[2:])
+		cmdBIN2MMDBInput = strings.TrimSpace(cmdBIN2MMDBInput)
+		cmdBIN2MMDBOutput = strings.TrimSpace(cmdBIN2MMDBOutput)
+		cmdBIN2MMDBType = strings.TrimSpace(cmdBIN2MMDBType)
+		if cmdBIN2MMDBInput == "" {
+			usageError("Input file not specified.")
+		}
+		if cmdBIN2MMDBOutput == "" {
+			usageError("Output file not specified.")
+		}
+		opts := convert.MMDBOptions{Type: cmdBIN2MMDBType}
+		err = convertBINFile(cmdBIN2MMDBInput, cmdBIN2MMDBOutput, convert.CheckMMDBFile, func(rdr *convert.BINReader, out *os.File) error {
+			return convert.ConvertBIN2MMDB(rdr, out, opts)
+		})
+	case "mmdb2bin":
+		cmdMMDB2BIN.Parse(os.Args[2:])
+		cmdMMDB2BINDBPackage = strings.TrimSpace(cmdMMDB2BINDBPackage)
+		cmdMMDB2BINInput = strings.TrimSpace(cmdMMDB2BINInput)
+		cmdMMDB2BINOutput = strings.TrimSpace(cmdMMDB2BINOutput)
+		regexDBPackage := regexp.MustCompile(`^(([1-9])|(1[0-9])|(2[0-6]))$`) // 1 to 26 for the DB packages
+
+		if !regexDBPackage.MatchString(cmdMMDB2BINDBPackage) {
+			usageError("DB package not specified.")
+		}
+		if cmdMMDB2BINInput == "" {
+			usageError("Input file not specified.")
+		}
+		if cmdMMDB2BINOutput == "" {
+			usageError("Output file not specified.")
+		}
+		opts := convert.MMDB2BINOptions{Package: parsePackage(cmdMMDB2BINDBPackage), Mapping: cmdMMDB2BINMapping}
+		err = convertMMDBFile(cmdMMDB2BINInput, cmdMMDB2BINOutput, convert.CheckBINFile, func(rdr *maxminddb.Reader, out *os.File) error {
+			return convert.ConvertMMDB2BIN(rdr, out, opts)
+		})
+	case "info":
+		cmdInfo.Parse(os.Args[2:])
+		cmdInfoInput = strings.TrimSpace(cmdInfoInput)
+		cmdInfoFormat = strings.ToLower(strings.TrimSpace(cmdInfoFormat))
+		if cmdInfoInput == "" {
+			usageError("Input file not specified.")
+		}
+		err = PrintFileInfo(cmdInfoInput, cmdInfoFormat)
+	case "lookup":
+		cmdLookup.Parse(os.Args[2:])
+		cmdLookupInput = strings.TrimSpace(cmdLookupInput)
+		cmdLookupFormat = strings.ToLower(strings.TrimSpace(cmdLookupFormat))
+		if cmdLookupInput == "" {
+			usageError("Input file not specified.")
 		}
-		WriteBIN(cmdCSV2BINInput, cmdCSV2BINOutput, cmdCSV2BINDBPackage)
+		err = LookupIPs(cmdLookupInput, cmdLookupFormat, cmdLookup.Args(), os.Stdin)
+	case "verify":
+		cmdVerify.Parse(os.Args[2:])
+		cmdVerifyInput = strings.TrimSpace(cmdVerifyInput)
+		cmdVerifyBIN = strings.TrimSpace(cmdVerifyBIN)
+		cmdVerifyDBPackage = strings.TrimSpace(cmdVerifyDBPackage)
+		cmdVerifyMMDB = strings.TrimSpace(cmdVerifyMMDB)
+		cmdVerifyType = strings.ToLower(strings.TrimSpace(cmdVerifyType))
+		regexDBPackage := regexp.MustCompile(`^(([1-9])|(1[0-9])|(2[0-6]))$`) // 1 to 26 for DB packages, 1 to 12 for PX packages
+		regexType := regexp.MustCompile(`^(country|city)$`)
+
+		if cmdVerifyInput == "" {
+			usageError("Input file not specified.")
+		}
+		if (cmdVerifyBIN == "") == (cmdVerifyMMDB == "") {
+			usageError("Specify either the BIN or the MMDB file.")
+		}
+
+		if cmdVerifyBIN != "" && !regexDBPackage.MatchString(cmdVerifyDBPackage) {
+			usageError("DB package not specified.")
+		}
+		if cmdVerifyMMDB != "" && !regexType.MatchString(cmdVerifyType) {
+			usageError("Invalid MMDB type.")
+		}
+		opts := convert.VerifyOptions{Package: parsePackage(cmdVerifyDBPackage), Type: cmdVerifyType, Sample: cmdVerifySample}
+		var res convert.VerifyResult
+		if res, err = VerifyFile(cmdVerifyInput, cmdVerifyBIN, cmdVerifyMMDB, opts); err == nil && !res.OK() {
+			os.Exit(exitMismatch) // so that scripts can stop before shipping the file
+		}
+	case "diff":
+		cmdDiff.Parse(os.Args[2:])
+		cmdDiffOld = strings.TrimSpace(cmdDiffOld)
+		cmdDiffNew = strings.TrimSpace(cmdDiffNew)
+		cmdDiffFormat = strings.ToLower(strings.TrimSpace(cmdDiffFormat))
+		if cmdDiffOld == "" {
+			usageError("Old file not specified.")
+		}
+		if cmdDiffNew == "" {
+			usageError("New file not specified.")
+		}
+		err = DiffFiles(cmdDiffOld, cmdDiffNew, cmdDiffFormat)
 	default:
 		flag.Parse()
 		if showVer {
@@ -106,6 +366,9 @@ func main() {
 			PrintUsage()
 		}
 	}
+	if err != nil {
+		fatal(err)
+	}
 }
 
 func PrintVersion() {
@@ -122,6 +385,12 @@ func PrintUsage() {
 
     -h                   Print this help
 
+NOTE:
+
+  The csv2mmdb, csv2bin, bin2csv, mmdb2csv, bin2mmdb and mmdb2bin commands read from stdin
+  with "-i -" and write to stdout with "-o -". The output written to stdout is not checked
+  before it is complete, and quarantine needs -quarantine with "-o -".
+
 
 To convert IP2Location DB1 CSV to MMDB (compatible with GeoLite2-Country MMDB format)
 
@@ -155,23 +424,291 @@ NOTE:
   OR download the free LITE DB9 from https://lite.ip2location.com
 
 
-To convert IP2Location DB IPv6 CSV to IP2Location BIN
+To convert IP2Location ASN or DB26 CSV to MMDB (compatible with GeoLite2-ASN MMDB format)
 
-  Usage: EXE csv2bin [OPTION]
+  Usage: EXE csv2mmdb -t asn [OPTION]
+
+    -i                   Specify the input path to the ASN or DB26 CSV file
+
+    -o                   Specify the output path to the MMDB file
+
+NOTE:
+
+  The conversion requires the IP2Location ASN or DB26 IPv6 CSV file.
+
+
+To convert IP2Location ASN or DB26 CSV to MMDB (compatible with GeoIP2-ISP MMDB format)
+
+  Usage: EXE csv2mmdb -t isp [OPTION]
+
+    -i                   Specify the input path to the ASN or DB26 CSV file
+
+    -o                   Specify the output path to the MMDB file
+
+NOTE:
+
+  The conversion requires the IP2Location ASN or DB26 IPv6 CSV file.
+
+  The ISP is also used as the organization. The ASN CSV uses the AS name for both.
+
+
+To convert IP2Proxy PX1 to PX12 CSV to MMDB
+
+  Usage: EXE csv2mmdb -t proxy [OPTION]
+
+    -i                   Specify the input path to the PX CSV file
+
+    -o                   Specify the output path to the MMDB file
+
+NOTE:
+
+  The PX package is detected from the number of columns in the CSV file.
+
+  The proxy type sets traits.is_anonymous_proxy or traits.is_hosting_provider (DCH).
+  The proxy_type, usage_type, threat, provider, last_seen and fraud_score are at the top level.
+
+
+To convert any IP2Location DB CSV to MMDB (GeoIP2 compatible fields with the rest under "ip2location")
+
+  Usage: EXE csv2mmdb -d DB_PACKAGE [OPTION]
 
     -d                   Specify the IP2Location DB package
                          Valid values: 1 to 26
 
     -i                   Specify the input path to the DB CSV file
 
-    -o                   Specify the output path to the BIN file
+    -o                   Specify the output path to the MMDB file
+
+    -on-error            Specify what to do with bad CSV records (optional)
+                         Valid values: abort, skip or quarantine
+                         Default is abort
+
+    -quarantine          Specify the output path for the quarantined CSV records (optional)
+                         Default is the output path with ".quarantine.csv" added
+
+    -j                   Specify the number of goroutines parsing the CSV file (optional)
+                         0 for the number of CPUs
+                         Default is 1
+
+    -entry               Specify the CSV file in the ZIP archive (optional)
+                         Default is the only .CSV file in the archive
+
+    -date                Specify the date of the database as YYYY-MM-DD (optional)
+                         Default is the date in the CSV file name, or else the
+                         modified date of the ZIP entry or of the CSV file, or today
 
 NOTE:
 
   The conversion requires the IP2Location DB IPv6 CSV file.
 
+  Fields with "-" are left out of the MMDB records.
+
+  The -on-error, -quarantine, -j, -entry and -date options work with all the csv2mmdb types.
+
+  The CSV file can be gzip, bzip2, xz or zstd compressed or in a ZIP archive.
+
+  The date is written as the MMDB build epoch and in the MMDB description.
+
+
+To convert IP2Location DB or IP2Proxy PX IPv6 or IPv4 CSV to BIN
+
+  Usage: EXE csv2bin [OPTION]
+
+    -p                   Specify the product of the CSV file (optional)
+                         Valid values: db (IP2Location) or px (IP2Proxy)
+                         Default is db
+
+    -d                   Specify the IP2Location DB or IP2Proxy PX package
+                         Valid values: 1 to 26 for DB, 1 to 12 for PX
+
+    -i                   Specify the input path to the DB CSV file
+
+    -o                   Specify the output path to the BIN file
+
+    -f                   Specify the IP family of the CSV file (optional)
+                         Valid values: 4 or 6
+                         Detected from the IP numbers if not specified
+
+    -on-error            Specify what to do with bad CSV records (optional)
+                         Valid values: abort, skip or quarantine
+                         Default is abort
+
+    -quarantine          Specify the output path for the quarantined CSV records (optional)
+                         Default is the output path with ".quarantine.csv" added
+
+    -j                   Specify the number of goroutines parsing the CSV file (optional)
+                         0 for the number of CPUs
+                         Default is 1
+
+    -entry               Specify the CSV file in the ZIP archive (optional)
+                         Default is the only .CSV file in the archive
+
+    -date                Specify the date of the database as YYYY-MM-DD (optional)
+                         Default is the date in the CSV file name, or else the
+                         modified date of the ZIP entry or of the CSV file, or today
+
+NOTE:
+
+  The conversion requires the IP2Location DB or IP2Proxy PX IPv6 or IPv4 CSV file.
+  IPv4 CSV will produce an IPv4-only BIN file.
+  IP2Proxy CSV only lists the proxy ranges, the rest are written as "-".
+  With skip or quarantine, the ranges of the bad records are written as "-".
+  The CSV file can be gzip, bzip2, xz or zstd compressed or in a ZIP archive.
+  The date is written in the BIN header and reported by the IP2Location SDKs.
+
   You can either subscribe to the commercial DB at https://www.ip2location.com
   OR download the free LITE DB from https://lite.ip2location.com
+
+
+To convert IP2Location or IP2Proxy BIN to IPv6 CSV
+
+  Usage: EXE bin2csv [OPTION]
+
+    -i                   Specify the input path to the BIN file
+
+    -o                   Specify the output path to the CSV file
+
+NOTE:
+
+  The DB package is detected from the BIN file. DB1 to DB26 are supported.
+  IPv4-only BIN files are converted to IP2Location DB IPv4 CSV.
+
+
+To convert GeoLite2-Country or GeoLite2-City style MMDB to IP2Location DB1 or DB9 IPv6 CSV
+
+  Usage: EXE mmdb2csv [OPTION]
+
+    -t                   Specify the CSV type
+                         Valid values: country (DB1) or city (DB9)
+
+    -i                   Specify the input path to the MMDB file
+
+    -o                   Specify the output path to the CSV file
+
+NOTE:
+
+  The CSV can be used as the input for csv2bin and csv2mmdb.
+  IP ranges not found in the MMDB file are filled with "-".
+
+
+To convert IP2Location or IP2Proxy BIN to MMDB
+
+  Usage: EXE bin2mmdb [OPTION]
+
+    -t                   Specify the MMDB type (optional)
+                         Valid values: country (DB1), city (DB9) or proxy
+                         Default is all the fields of the DB package in the BIN file
+
+    -i                   Specify the input path to the BIN file
+
+    -o                   Specify the output path to the MMDB file
+
+NOTE:
+
+  The MMDB records are the same as csv2mmdb with -d (or -t) on the CSV from bin2csv.
+  The city type requires a BIN with the DB9 fields.
+  IP2Proxy BIN files are always converted with the proxy type.
+
+
+To convert MMDB to IP2Location BIN
+
+  Usage: EXE mmdb2bin [OPTION]
+
+    -d                   Specify the IP2Location DB package
+                         Valid values: 1 to 26
+
+    -i                   Specify the input path to the MMDB file
+
+    -o                   Specify the output path to the BIN file
+
+    -m                   Specify the MMDB path for the BIN columns (optional)
+                         Format: column=path,column=path
+                         Example: isp=traits.organization,region=subdivisions.1.names.en
+
+NOTE:
+
+  The default MMDB paths are the same fields that csv2mmdb -d writes.
+  Valid columns: country_code, country_name, region, city, latitude, longitude,
+  zip_code, time_zone, isp, domain, net_speed, idd_code, area_code,
+  weather_station_code, weather_station_name, mcc, mnc, mobile_brand, elevation,
+  usage_type, address_type, category, district, asn, as
+  Use "|" between paths to use the first one found.
+  IP ranges not found in the MMDB file are written as "-".
+
+
+To print the BIN header or MMDB metadata
+
+  Usage: EXE info [OPTION]
+
+    -i                   Specify the input path to the BIN or MMDB file
+
+    -f                   Specify the output format (optional)
+                         Valid values: text or json
+                         Default is text
+
+NOTE:
+
+  The file type is detected from the file content.
+
+
+To look up IP addresses in a BIN or MMDB file
+
+  Usage: EXE lookup [OPTION] [IP]...
+
+    -i                   Specify the input path to the BIN or MMDB file
+
+    -f                   Specify the output format (optional)
+                         Valid values: table or json (one line per IP address)
+                         Default is table
+
+NOTE:
+
+  The IP addresses are read from the standard input, one per line, if not specified.
+  Only the populated fields are printed. MMDB fields are printed as paths like country.iso_code.
+
+
+To verify a BIN or MMDB file against the CSV it was generated from
+
+  Usage: EXE verify [OPTION]
+
+    -i                   Specify the input path to the IP2Location or IP2Proxy CSV file
+
+    -b                   Specify the path to the BIN file to verify
+
+    -d                   Specify the DB or PX package of the BIN
+                         Valid values: 1 - 26 for DB packages, 1 - 12 for PX packages
+
+    -m                   Specify the path to the MMDB file from csv2mmdb to verify
+
+    -t                   Specify the MMDB type used with csv2mmdb
+                         Valid values: country or city
+
+    -s                   Check only 1 in every N ranges for very large files (optional)
+                         Default is 1 which checks every range
+
+NOTE:
+
+  The start, middle and end IP addresses of each CSV range are looked up in the BIN or MMDB and the fields
+  are compared. The mismatches and the missing ranges are printed with the CSV line numbers.
+  Exits with status 9 if any mismatch is found.
+
+
+To compare 2 releases of a BIN or MMDB file
+
+  Usage: EXE diff [OPTION]
+
+    -a                   Specify the path to the old BIN or MMDB file
+
+    -b                   Specify the path to the new BIN or MMDB file
+
+    -f                   Specify the output format (optional)
+                         Valid values: text or json (one line per IP range)
+                         Default is text
+
+NOTE:
+
+  The IP ranges with changed fields are printed with the old and new values, followed by the number
+  of ranges and IP addresses changed for each field. IPv4 ranges are printed as plain IPv4.
 `
 
 	usage = strings.ReplaceAll(usage, "EXE", os.Args[0])